@@ -0,0 +1,110 @@
+package minformat
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"go/version"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rewriteAny replaces the empty interface types with any if toAny is true
+// and the any type uses with empty interface types otherwise.
+//
+// Only the predeclared any is considered, so the shadowed names are left as is.
+func rewriteAny(tc *typeChecker, files []*ast.File, toAny bool) error {
+	if err := tc.check(files); err != nil {
+		return err
+	}
+	universeAny := types.Universe.Lookup("any")
+	for _, f := range files {
+		rewrite(f, func(n ast.Node) ast.Node {
+			switch n := n.(type) {
+			case *ast.InterfaceType:
+				if !toAny || len(n.Methods.List) != 0 {
+					break
+				}
+				scope := tc.pkg.Scope().Innermost(n.Pos())
+				if scope == nil {
+					break
+				}
+				if _, obj := scope.LookupParent("any", n.Pos()); obj == universeAny {
+					return &ast.Ident{Name: "any", NamePos: n.Pos()}
+				}
+			case *ast.Ident:
+				if !toAny && tc.info.Uses[n] == universeAny {
+					return &ast.InterfaceType{Interface: n.Pos(), Methods: &ast.FieldList{}}
+				}
+			}
+			return n
+		})
+	}
+	return nil
+}
+
+// ModuleGoVersion returns the Go language version, like "go1.18",
+// declared by the go.mod file of the module that contains dir.
+//
+// If the module doesn't declare the language version, the result is empty.
+func ModuleGoVersion(dir string) (string, error) {
+	filename, err := findModFile(dir)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return modFileGoVersion(f)
+}
+
+// findModFile returns the path of the go.mod file of the module that contains dir.
+func findModFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		filename := filepath.Join(dir, "go.mod")
+		_, err := os.Stat(filename)
+		if err == nil {
+			return filename, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("go.mod not found")
+		}
+		dir = parent
+	}
+}
+
+func modFileGoVersion(f *os.File) (string, error) {
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.Index(line, "//"); i != -1 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "go" {
+			return "go" + fields[1], nil
+		}
+	}
+	return "", s.Err()
+}
+
+// parseGoVersion returns v in the "go1.18" form used by go/version.
+// Both "go1.18" and "1.18.2" forms are accepted.
+func parseGoVersion(v string) (string, bool) {
+	if !strings.HasPrefix(v, "go") {
+		v = "go" + v
+	}
+	return v, version.IsValid(v)
+}
@@ -0,0 +1,87 @@
+package minformat
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteAny(t *testing.T) {
+	tests := []struct {
+		goVersion string
+		src       string
+		want      string
+	}{
+		{"go1.18", `var x interface{}`, `var x any`},
+		{"1.21", `func f(x interface{}) []interface{} { return nil }`, `func f(x any)[]any{return nil}`},
+		{"go1.18", `var x map[string]interface{}`, `var x map[string]any`},
+		{"go1.18", `func f[T interface{}](x T) {}`, `func f[T any](x T){}`},
+		{"go1.18", `var x interface{ M() }`, `var x interface{M()}`},
+
+		// Shadowed any.
+		{"go1.18", `type any int; var x interface{}`, `type any int;var x interface{}`},
+		{"go1.18", `func f(any int) { var x interface{}; _ = x }`, `func f(any int){var x interface{};_=x}`},
+		{"go1.18", `func f() { var x interface{}; any := 1; _, _ = x, any }`, `func f(){var x any;any:=1;_,_=x,any}`},
+
+		{"1.18.2", `var x interface{}`, `var x any`},
+		{"go1.18beta1", `var x interface{}`, `var x any`},
+		{"go1.21rc1", `var x interface{}`, `var x any`},
+		{"go1.17", `var x interface{}`, `var x interface{}`},
+		{"go1.17.13", `var x interface{}`, `var x interface{}`},
+		{"go1.18", `var x any`, `var x any`},
+	}
+
+	for _, test := range tests {
+		cfg := &Config{RewriteAny: true, GoVersion: test.goVersion}
+		have, err := cfg.Source([]byte("package p;" + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestRewriteAnyReverse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`var x any`, `var x interface{}`},
+		{`func f(x any) []any { return nil }`, `func f(x interface{})[]interface{}{return nil}`},
+		{`type any = int; var x any`, `type any=int;var x any`},
+	}
+
+	cfg := &Config{RewriteAny: true, GoVersion: "go1.17"}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package p;" + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestModuleGoVersion(t *testing.T) {
+	dir := t.TempDir()
+	mod := "module example.com/m\n\ngo 1.21 // comment\n\nrequire example.com/x v1.0.0\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(mod), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	v, err := ModuleGoVersion(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "go1.21" {
+		t.Errorf("version mismatch:\nhave: %q\nwant: %q", v, "go1.21")
+	}
+}
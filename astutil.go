@@ -0,0 +1,127 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// isGlobalIdent reports whether n is an identifier that doesn't refer
+// to any object declared in the package; package names and predeclared identifiers are global.
+//
+// The identifiers info knows nothing about, like the ones added by the transformations,
+// are global too.
+func isGlobalIdent(info *types.Info, n ast.Expr, name string) bool {
+	ident, ok := n.(*ast.Ident)
+	if !ok || ident.Name != name {
+		return false
+	}
+	switch obj := info.ObjectOf(ident).(type) {
+	case nil, *types.PkgName:
+		return true
+	default:
+		return obj.Parent() == types.Universe
+	}
+}
+
+// isPkgIdent reports whether n is an identifier that refers to an imported package.
+func isPkgIdent(info *types.Info, n ast.Expr) bool {
+	ident, ok := n.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	_, ok = info.ObjectOf(ident).(*types.PkgName)
+	return ok
+}
+
+// boolConst returns the value of n if it's a predeclared true or false.
+func boolConst(info *types.Info, n ast.Expr) (value, ok bool) {
+	n = unparen(n)
+	switch {
+	case isGlobalIdent(info, n, "true"):
+		return true, true
+	case isGlobalIdent(info, n, "false"):
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func newBoolConst(v bool, pos token.Pos) *ast.Ident {
+	if v {
+		return &ast.Ident{Name: "true", NamePos: pos}
+	}
+	return &ast.Ident{Name: "false", NamePos: pos}
+}
+
+// isSafeExpr reports whether n can be removed without changing the program behavior.
+// It's conservative: any expression that may panic or have a side effect is not safe.
+func isSafeExpr(info *types.Info, n ast.Expr) bool {
+	switch n := n.(type) {
+	case *ast.Ident, *ast.BasicLit:
+		return true
+	case *ast.ParenExpr:
+		return isSafeExpr(info, n.X)
+	case *ast.SelectorExpr:
+		// Only a package-qualified name can't cause a nil dereference.
+		return isPkgIdent(info, n.X)
+	case *ast.UnaryExpr:
+		return n.Op != token.ARROW && n.Op != token.AND && isSafeExpr(info, n.X)
+	case *ast.BinaryExpr:
+		return n.Op != token.QUO && n.Op != token.REM &&
+			n.Op != token.SHL && n.Op != token.SHR &&
+			isSafeExpr(info, n.X) && isSafeExpr(info, n.Y)
+	default:
+		return false
+	}
+}
+
+// localVars returns the function-local variables referenced inside n.
+// The variables are returned in the order of their first appearance.
+func localVars(info *types.Info, n ast.Node) []*types.Var {
+	var list []*types.Var
+	seen := make(map[*types.Var]bool)
+	ast.Inspect(n, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		v, ok := info.ObjectOf(ident).(*types.Var)
+		if !ok || seen[v] || v.IsField() || v.Parent() == nil || v.Parent() == v.Pkg().Scope() {
+			return true
+		}
+		seen[v] = true
+		list = append(list, v)
+		return true
+	})
+	return list
+}
+
+func unparen(n ast.Expr) ast.Expr {
+	for {
+		p, ok := n.(*ast.ParenExpr)
+		if !ok {
+			return n
+		}
+		n = p.X
+	}
+}
+
+// recvBaseName returns the name of the receiver base type expressed by n,
+// like T for *T or T[K, V].
+func recvBaseName(n ast.Expr) string {
+	for {
+		switch x := unparen(n).(type) {
+		case *ast.StarExpr:
+			n = x.X
+		case *ast.IndexExpr:
+			n = x.X
+		case *ast.IndexListExpr:
+			n = x.X
+		case *ast.Ident:
+			return x.Name
+		default:
+			return "?"
+		}
+	}
+}
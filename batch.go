@@ -0,0 +1,284 @@
+package minformat
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/scanner"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// Batch minifies many files concurrently.
+//
+// Every file is treated as a single-file package, like Config.Source does.
+// The transformations that need the other files of the package, the ones that
+// require the type information and HoistStrings, would break the multi-file
+// packages, so Run rejects them; use Config.Transform or Config.Package instead.
+// So does it reject BuildContext: the files are parsed without their names,
+// so the _GOOS_GOARCH suffixes couldn't exclude them.
+type Batch struct {
+	// Config enables the transformations; nil means the default Config.
+	Config *Config
+
+	// Jobs is the number of files processed at once;
+	// if it's not positive, runtime.GOMAXPROCS(0) is used.
+	Jobs int
+
+	// Stats enables the size statistics in the results.
+	Stats bool
+
+	// Tokens minifies the files with TokenSource, so Config is ignored.
+	Tokens bool
+
+	// Cache, if not nil, stores the results of the files minified successfully,
+	// so the files with the same contents are not minified again.
+	// The keys identify the contents, the effective Config, Stats included,
+	// and the version of the minification.
+	//
+	// Cache is not used if Config.Report or Config.Importer is set.
+	Cache Cache
+}
+
+// Cache stores the data by the keys, see Batch.Cache.
+//
+// A Cache must be safe for concurrent use. It doesn't report errors:
+// an entry that can't be stored is simply not found later.
+type Cache interface {
+	Get(key string) (data []byte, ok bool)
+	Put(key string, data []byte)
+}
+
+// cacheEntry is the Cache data of a FileResult.
+type cacheEntry struct {
+	Output []byte
+	Stats  *Stats
+}
+
+// FileResult is the result of minifying a file by Batch.
+type FileResult struct {
+	Filename string
+	Output   []byte
+
+	// Stats is set if Batch.Stats is enabled.
+	Stats *Stats
+
+	// Err is the error that made the file minification fail.
+	Err error
+}
+
+// Run minifies the files and passes the results to fn in the order of filenames.
+// fn is never called concurrently; the file errors are passed to it as well.
+//
+// At most twice Jobs files are read but not yet passed to fn, so the memory
+// is bounded regardless of the number of files.
+// Run stops when ctx is done or fn returns an error and returns that error;
+// the files that are being processed at the moment are not interrupted.
+func (b *Batch) Run(ctx context.Context, filenames []string, fn func(*FileResult) error) error {
+	cfg := b.Config
+	if cfg == nil {
+		cfg = defaultConfig
+	}
+	if name := packageOption(cfg); name != "" && !b.Tokens {
+		return fmt.Errorf("batch: %s needs the whole package", name)
+	}
+	jobs := b.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	var configKey string
+	if b.Cache != nil && cfg.Report == nil && cfg.Importer == nil {
+		configKey = b.configKey(cfg)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	type task struct {
+		filename string
+		result   chan *FileResult
+	}
+	tasks := make(chan task)
+	// pending are the tasks in the filenames order;
+	// its capacity limits the number of results kept in memory.
+	pending := make(chan task, 2*jobs)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(pending)
+		for _, filename := range filenames {
+			t := task{filename: filename, result: make(chan *FileResult, 1)}
+			select {
+			case pending <- t:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case tasks <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case t := <-tasks:
+					t.result <- b.minifyFile(cfg, configKey, t.filename)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for t := range pending {
+		if err := ctx.Err(); err != nil {
+			// Both cases of the select below may be ready.
+			return err
+		}
+		select {
+		case r := <-t.result:
+			if err := fn(r); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return ctx.Err()
+}
+
+// minifyFile minifies the file; if configKey is not empty, it uses b.Cache.
+func (b *Batch) minifyFile(cfg *Config, configKey, filename string) *FileResult {
+	r := &FileResult{Filename: filename}
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	var key string
+	if configKey != "" {
+		h := sha256.New()
+		h.Write([]byte(configKey))
+		h.Write(src)
+		key = hex.EncodeToString(h.Sum(nil))
+		var entry cacheEntry
+		if data, ok := b.Cache.Get(key); ok && json.Unmarshal(data, &entry) == nil {
+			r.Output, r.Stats = entry.Output, entry.Stats
+			return r
+		}
+	}
+
+	switch {
+	case b.Tokens && b.Stats:
+		r.Output, r.Stats, r.Err = TokenSourceStats(src)
+	case b.Tokens:
+		var buf bytes.Buffer
+		r.Err = TokenSource(&buf, bytes.NewReader(src))
+		r.Output = buf.Bytes()
+	case b.Stats:
+		r.Output, r.Stats, r.Err = cfg.SourceStats(src)
+	default:
+		r.Output, r.Err = cfg.Source(src)
+	}
+	if list, ok := r.Err.(scanner.ErrorList); ok {
+		for _, e := range list {
+			e.Pos.Filename = filename
+		}
+	}
+	if key != "" && r.Err == nil {
+		if data, err := json.Marshal(cacheEntry{Output: r.Output, Stats: r.Stats}); err == nil {
+			b.Cache.Put(key, data)
+		}
+	}
+	return r
+}
+
+// packageOption returns the name of the first Config field enabling
+// a transformation that depends on the other files of the package, if any.
+func packageOption(cfg *Config) string {
+	switch {
+	case cfg.BuildContext != nil:
+		return "BuildContext"
+	case len(cfg.StripCalls) != 0:
+		return "StripCalls"
+	case cfg.RemoveUnused:
+		return "RemoveUnused"
+	case cfg.Skeleton:
+		return "Skeleton"
+	case cfg.ElideTypes:
+		return "ElideTypes"
+	case cfg.ShortenStmts:
+		return "ShortenStmts"
+	case cfg.RewriteAny:
+		return "RewriteAny"
+	case cfg.PositionalLits:
+		return "PositionalLits"
+	case cfg.HoistStrings:
+		return "HoistStrings"
+	}
+	return ""
+}
+
+// cacheVersion identifies the minification results in the Cache keys.
+// It must be changed whenever the results for the same input and options change.
+const cacheVersion = 1
+
+// configKey returns a description of the options that affect the results.
+//
+// The options are listed one by one: the func fields of build.Context
+// have no stable representation, and Importer and Report disable the cache.
+func (b *Batch) configKey(cfg *Config) string {
+	var key strings.Builder
+	fmt.Fprintf(&key, "minformat=%d,%s stats=%t tokens=%t", cacheVersion, moduleVersion(), b.Stats, b.Tokens)
+	if b.Tokens {
+		// Config is ignored.
+		return key.String() + "\x00"
+	}
+	fmt.Fprintf(&key, " cleanup=%t strip=%q stripSideEffects=%t removeUnused=%t shorten=%t elide=%t",
+		cfg.Cleanup, cfg.StripCalls, cfg.StripSideEffects, cfg.RemoveUnused, cfg.ShortenStmts, cfg.ElideTypes)
+	fmt.Fprintf(&key, " go=%q any=%t signatures=%t tags=%t positional=%t hoist=%t group=%t skeleton=%t stubs=%t",
+		cfg.GoVersion, cfg.RewriteAny, cfg.CompactSignatures, cfg.NormalizeTags, cfg.PositionalLits,
+		cfg.HoistStrings, cfg.GroupDecls, cfg.Skeleton, cfg.SkeletonStubs)
+	if ctxt := cfg.BuildContext; ctxt != nil {
+		fmt.Fprintf(&key, " goos=%q goarch=%q cgo=%t allFiles=%t compiler=%q buildTags=%q toolTags=%q releaseTags=%q",
+			ctxt.GOOS, ctxt.GOARCH, ctxt.CgoEnabled, ctxt.UseAllFiles, ctxt.Compiler,
+			ctxt.BuildTags, ctxt.ToolTags, ctxt.ReleaseTags)
+	}
+	return key.String() + "\x00"
+}
+
+// moduleVersion returns the version of this module the program is built with,
+// if it's known.
+func moduleVersion() string {
+	const path = "github.com/go-toolsmith/minformat"
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	if info.Main.Path == path {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == path {
+			if dep.Replace != nil {
+				return dep.Replace.Path + "@" + dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return ""
+}
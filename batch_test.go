@@ -0,0 +1,260 @@
+package minformat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/build"
+	"go/scanner"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	dir := t.TempDir()
+	var filenames []string
+	for i := 0; i < 50; i++ {
+		filename := filepath.Join(dir, fmt.Sprintf("f%d.go", i))
+		src := fmt.Sprintf("package p\n\nconst c%d = %d\n", i, i)
+		if i == 7 {
+			src = "package p\n\nconst = 1\n"
+		}
+		if err := os.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+	filenames = append(filenames, filepath.Join(dir, "missing.go"))
+
+	var results []*FileResult
+	b := &Batch{Jobs: 4, Stats: true}
+	err := b.Run(context.Background(), filenames, func(r *FileResult) error {
+		results = append(results, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(filenames) {
+		t.Fatalf("results: have %d, want %d", len(results), len(filenames))
+	}
+	for i, r := range results {
+		if r.Filename != filenames[i] {
+			t.Errorf("result %d: have %s, want %s", i, r.Filename, filenames[i])
+		}
+		switch i {
+		case 7:
+			var list scanner.ErrorList
+			if !errors.As(r.Err, &list) || list[0].Pos.Filename != filenames[i] {
+				t.Errorf("result %d: have error %v, want a syntax error in %s", i, r.Err, filenames[i])
+			}
+		case len(filenames) - 1:
+			if !errors.Is(r.Err, os.ErrNotExist) {
+				t.Errorf("result %d: have error %v, want %v", i, r.Err, os.ErrNotExist)
+			}
+		default:
+			want := fmt.Sprintf("package p;const c%d=%d", i, i)
+			if r.Err != nil || string(r.Output) != want || r.Stats == nil {
+				t.Errorf("result %d: have %q, %v, want %q", i, r.Output, r.Err, want)
+			}
+		}
+	}
+}
+
+func TestBatchCancel(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "f.go")
+	if err := os.WriteFile(filename, []byte("package p"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	filenames := make([]string, 100)
+	for i := range filenames {
+		filenames[i] = filename
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err := (&Batch{Jobs: 2}).Run(context.Background(), filenames, func(r *FileResult) error {
+		n++
+		if n == 10 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 10 {
+		t.Errorf("stop by fn: have %v after %d results, want %v after 10", err, n, stop)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n = 0
+	err = (&Batch{Jobs: 2}).Run(ctx, filenames, func(r *FileResult) error {
+		n++
+		if n == 10 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled || n != 10 {
+		t.Errorf("cancel: have %v after %d results, want %v after 10", err, n, context.Canceled)
+	}
+}
+
+type mapCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	hits    int
+}
+
+func (c *mapCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.entries[key]
+	if ok {
+		c.hits++
+	}
+	return data, ok
+}
+
+func (c *mapCache) Put(key string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = data
+}
+
+func TestBatchCache(t *testing.T) {
+	dir := t.TempDir()
+	sources := []string{
+		"package p\n\nfunc f() {\n\treturn\n}\n",
+		"package p\n\nconst = 1\n",
+		"package p\n\nfunc f() {\n\treturn\n}\n",
+	}
+	var filenames []string
+	for i, src := range sources {
+		filename := filepath.Join(dir, fmt.Sprintf("f%d.go", i))
+		if err := os.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		filenames = append(filenames, filename)
+	}
+
+	cache := &mapCache{entries: make(map[string][]byte)}
+	run := func(b *Batch) []string {
+		b.Cache = cache
+		var outputs []string
+		err := b.Run(context.Background(), filenames, func(r *FileResult) error {
+			if b.Stats && r.Err == nil && r.Stats == nil {
+				t.Errorf("%s: no stats", r.Filename)
+			}
+			outputs = append(outputs, fmt.Sprintf("%q %v", r.Output, r.Err != nil))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return outputs
+	}
+
+	tests := []struct {
+		batch   *Batch
+		entries int
+		hits    int
+	}{
+		// The same contents share the entry, the errors are not cached.
+		{&Batch{Jobs: 1}, 1, 1},
+		{&Batch{Jobs: 1}, 1, 3},
+		{&Batch{Jobs: 1, Stats: true}, 2, 4},
+		{&Batch{Jobs: 1, Config: &Config{Cleanup: true}}, 3, 5},
+		{&Batch{Jobs: 1, Config: &Config{Cleanup: true}}, 3, 7},
+		// The reports can't be cached.
+		{&Batch{Jobs: 1, Config: &Config{Cleanup: true, Report: func(token.Position, string) {}}}, 3, 7},
+	}
+	var want []string
+	for i, test := range tests {
+		have := run(test.batch)
+		if len(cache.entries) != test.entries || cache.hits != test.hits {
+			t.Errorf("run %d: have %d entries and %d hits, want %d and %d",
+				i, len(cache.entries), cache.hits, test.entries, test.hits)
+		}
+		if i == 0 || i == 3 {
+			want = have
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("run %d:\nhave: %v\nwant: %v", i, have, want)
+		}
+	}
+}
+
+func TestBatchConfigKey(t *testing.T) {
+	var b Batch
+	base := b.configKey(&Config{})
+
+	// Every option must affect the key.
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Name == "Importer" || field.Name == "Report" {
+			// These disable the cache.
+			continue
+		}
+		var cfg Config
+		v := reflect.ValueOf(&cfg).Elem().Field(i)
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			v.SetBool(true)
+		case reflect.String:
+			v.SetString("go1.18")
+		case reflect.Slice:
+			v.Set(reflect.ValueOf([]string{"println"}))
+		case reflect.Ptr:
+			v.Set(reflect.ValueOf(&build.Context{GOOS: "linux"}))
+		default:
+			t.Fatalf("unexpected %s field type %s", field.Name, field.Type)
+		}
+		if b.configKey(&cfg) == base {
+			t.Errorf("%s doesn't affect the key", field.Name)
+		}
+	}
+
+	// The func fields of the build context don't.
+	x, y := build.Default, build.Default
+	y.OpenFile = func(string) (io.ReadCloser, error) { return nil, errors.New("unused") }
+	if b.configKey(&Config{BuildContext: &x}) != b.configKey(&Config{BuildContext: &y}) {
+		t.Errorf("the build context funcs affect the key")
+	}
+}
+
+func TestBatchPackageOptions(t *testing.T) {
+	tests := []struct {
+		batch *Batch
+		err   string
+	}{
+		{&Batch{Config: &Config{Cleanup: true, GroupDecls: true}}, ``},
+		{&Batch{Config: &Config{RemoveUnused: true}}, `batch: RemoveUnused needs the whole package`},
+		{&Batch{Config: &Config{HoistStrings: true}}, `batch: HoistStrings needs the whole package`},
+		{&Batch{Config: &Config{StripCalls: []string{"println"}}}, `batch: StripCalls needs the whole package`},
+		{&Batch{Config: &Config{BuildContext: testBuildContext("linux", "amd64")}}, `batch: BuildContext needs the whole package`},
+		// Config is ignored.
+		{&Batch{Config: &Config{HoistStrings: true}, Tokens: true}, ``},
+	}
+
+	// The file name excludes it on linux.
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "f_windows.go")
+	if err := os.WriteFile(filename, []byte("package p"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for i, test := range tests {
+		have := ""
+		if err := test.batch.Run(context.Background(), []string{filename}, func(*FileResult) error { return nil }); err != nil {
+			have = err.Error()
+		}
+		if have != test.err {
+			t.Errorf("run %d:\nhave error: %s\nwant error: %s", i, have, test.err)
+		}
+	}
+}
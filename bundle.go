@@ -0,0 +1,356 @@
+package minformat
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Bundle returns a single file of the main package located in dir
+// with all packages of the same module it imports, directly or indirectly,
+// inlined into it. The other imports, like the standard library ones, are left as is.
+//
+// The package-level names of the inlined packages get a prefix derived from
+// the package name, their init functions run before the package variables
+// of the packages that import them are initialized, like without the inlining.
+// The result is merged and transformed like Package does.
+//
+// The packages with cgo or assembly files can't be inlined.
+// The unexported fields and methods of the inlined packages become
+// visible to each other, which can make some selectors ambiguous.
+func (cfg *Config) Bundle(fset *token.FileSet, dir string) (*ast.File, error) {
+	modFile, err := findModFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	modPath, err := readModulePath(modFile)
+	if err != nil {
+		return nil, err
+	}
+	b := &bundler{
+		fset:     fset,
+		ctxt:     cfg.BuildContext,
+		importer: cfg.Importer,
+		modDir:   filepath.Dir(modFile),
+		modPath:  modPath,
+		pkgs:     make(map[string]*bundlePackage),
+		renamed:  make(map[types.Object]string),
+	}
+	if b.ctxt == nil {
+		b.ctxt = &build.Default
+	}
+	if b.importer == nil {
+		b.importer = importer.ForCompiler(fset, "source", nil)
+	}
+
+	main, err := b.load("", dir)
+	if err != nil {
+		return nil, err
+	}
+	if main.pkg.Name() != "main" {
+		return nil, fmt.Errorf("bundle: %s is not a main package", dir)
+	}
+	b.rename()
+
+	var files []*ast.File
+	for _, p := range b.order {
+		for _, f := range p.files {
+			b.rewriteFile(p, f)
+		}
+		if p != main {
+			b.inlineInit(p)
+		}
+		files = append(files, p.files...)
+	}
+	return cfg.Package(fset, files)
+}
+
+// bundlePackage is a package inlined by Bundle.
+type bundlePackage struct {
+	path  string
+	files []*ast.File
+	pkg   *types.Package
+	info  *types.Info
+
+	// inits are the new names of the package init functions.
+	inits []string
+}
+
+type bundler struct {
+	fset     *token.FileSet
+	ctxt     *build.Context
+	importer types.Importer
+
+	modDir  string
+	modPath string
+
+	pkgs map[string]*bundlePackage
+	// order are the loaded packages, the dependencies go first.
+	order []*bundlePackage
+
+	// renamed maps the objects of the inlined packages to their new names.
+	renamed map[types.Object]string
+}
+
+// Import implements types.Importer for the packages of the bundled module.
+func (b *bundler) Import(path string) (*types.Package, error) {
+	if p := b.pkgs[path]; p != nil {
+		return p.pkg, nil
+	}
+	return b.importer.Import(path)
+}
+
+func (b *bundler) isLocal(path string) bool {
+	return inModule(b.modPath, path)
+}
+
+// inModule reports whether the package path belongs to the module modPath.
+func inModule(modPath, path string) bool {
+	return path == modPath || strings.HasPrefix(path, modPath+"/")
+}
+
+// load loads the package located in dir with all local packages it imports.
+func (b *bundler) load(path, dir string) (*bundlePackage, error) {
+	bp, err := b.ctxt.ImportDir(dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(bp.CgoFiles) != 0 || len(bp.SFiles) != 0 {
+		return nil, fmt.Errorf("bundle: package %s uses cgo or assembly", bp.Dir)
+	}
+	for _, imp := range bp.Imports {
+		if !b.isLocal(imp) || b.pkgs[imp] != nil {
+			continue
+		}
+		rel := strings.TrimPrefix(imp, b.modPath)
+		if _, err := b.load(imp, filepath.Join(b.modDir, filepath.FromSlash(rel))); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &bundlePackage{path: path}
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(b.fset, filepath.Join(bp.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		p.files = append(p.files, f)
+	}
+	p.info = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+	conf := types.Config{Importer: b}
+	pkgPath := path
+	if pkgPath == "" {
+		pkgPath = "main"
+	}
+	p.pkg, err = conf.Check(pkgPath, b.fset, p.files, p.info)
+	if err != nil {
+		return nil, fmt.Errorf("type-check: %w", err)
+	}
+	if path != "" {
+		b.pkgs[path] = p
+	}
+	b.order = append(b.order, p)
+	return p, nil
+}
+
+// rename chooses the new names for the package-level objects of the inlined packages.
+// The new names are not used anywhere, so they can't conflict with the other names.
+func (b *bundler) rename() {
+	used := make(map[string]bool)
+	for _, p := range b.order {
+		for _, f := range p.files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok {
+					used[ident.Name] = true
+				}
+				return true
+			})
+		}
+	}
+
+	prefixes := make(map[string]bool)
+	for _, p := range b.order[:len(b.order)-1] {
+		scope := p.pkg.Scope()
+		numInits := 0
+		for _, f := range p.files {
+			for _, decl := range f.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "init" {
+					numInits++
+				}
+			}
+		}
+		newName := func(prefix, name string) string { return prefix + "_" + name }
+
+		prefix := p.pkg.Name()
+		for i := 1; ; i++ {
+			ok := !prefixes[prefix]
+			for _, name := range scope.Names() {
+				ok = ok && !used[newName(prefix, name)]
+			}
+			for j := 0; j < numInits; j++ {
+				ok = ok && !used[newName(prefix, "init"+strconv.Itoa(j))]
+			}
+			if ok {
+				break
+			}
+			prefix = p.pkg.Name() + strconv.Itoa(i)
+		}
+		prefixes[prefix] = true
+
+		for _, name := range scope.Names() {
+			b.renamed[scope.Lookup(name)] = newName(prefix, name)
+			used[newName(prefix, name)] = true
+		}
+		for j := 0; j < numInits; j++ {
+			p.inits = append(p.inits, newName(prefix, "init"+strconv.Itoa(j)))
+			used[newName(prefix, "init"+strconv.Itoa(j))] = true
+		}
+	}
+
+	// The embedded fields are named after their types.
+	for _, p := range b.order {
+		for _, obj := range p.info.Defs {
+			field, ok := obj.(*types.Var)
+			if !ok || !field.Embedded() {
+				continue
+			}
+			typ := field.Type()
+			if ptr, ok := typ.(*types.Pointer); ok {
+				typ = ptr.Elem()
+			}
+			var typeName types.Object
+			switch typ := typ.(type) {
+			case *types.Named:
+				typeName = typ.Origin().Obj()
+			case *types.Alias:
+				typeName = typ.Obj()
+			}
+			if name, ok := b.renamed[typeName]; ok {
+				b.renamed[field] = name
+			}
+		}
+	}
+}
+
+// rewriteFile renames the references to the inlined packages objects in f
+// and removes their imports.
+func (b *bundler) rewriteFile(p *bundlePackage, f *ast.File) {
+	rewrite(f, func(n ast.Node) ast.Node {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return n
+		}
+		x, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return n
+		}
+		if pkgName, ok := p.info.Uses[x].(*types.PkgName); !ok || !b.isLocal(pkgName.Imported().Path()) {
+			return n
+		}
+		if name, ok := b.renamed[originObject(p.info.Uses[sel.Sel])]; ok {
+			return &ast.Ident{Name: name, NamePos: sel.Pos()}
+		}
+		return n
+	})
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := p.info.Uses[ident]
+		if obj == nil {
+			obj = p.info.Defs[ident]
+		}
+		if name, ok := b.renamed[originObject(obj)]; ok {
+			ident.Name = name
+		}
+		return true
+	})
+
+	keep := make(map[*ast.ImportSpec]bool)
+	for _, spec := range f.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); !b.isLocal(path) {
+			keep[spec] = true
+		}
+	}
+	removeImports(f, keep)
+	f.Name.Name = "main"
+}
+
+// inlineInit turns the init functions of p into regular functions that are
+// called by a package variable initializer declared after all p variables.
+//
+// The variables are initialized in the declaration order unless they
+// depend on each other, so the initializer runs before the variables
+// of the packages that import p are initialized.
+func (b *bundler) inlineInit(p *bundlePackage) {
+	if len(p.inits) == 0 {
+		return
+	}
+	body := &ast.BlockStmt{}
+	i := 0
+	for _, f := range p.files {
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && fn.Name.Name == "init" {
+				fn.Name.Name = p.inits[i]
+				body.List = append(body.List, &ast.ExprStmt{X: &ast.CallExpr{Fun: &ast.Ident{Name: p.inits[i]}}})
+				i++
+			}
+		}
+	}
+	body.List = append(body.List, &ast.ReturnStmt{Results: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "0"}}})
+
+	last := p.files[len(p.files)-1]
+	last.Decls = append(last.Decls, &ast.GenDecl{
+		Tok: token.VAR,
+		Specs: []ast.Spec{&ast.ValueSpec{
+			Names: []*ast.Ident{{Name: "_"}},
+			Values: []ast.Expr{&ast.CallExpr{Fun: &ast.FuncLit{
+				Type: &ast.FuncType{
+					Params:  &ast.FieldList{},
+					Results: &ast.FieldList{List: []*ast.Field{{Type: &ast.Ident{Name: "int"}}}},
+				},
+				Body: body,
+			}}},
+		}},
+	})
+}
+
+// readModulePath returns the module path declared by the go.mod file.
+func readModulePath(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && fields[0] == "module" {
+			if path, err := strconv.Unquote(fields[1]); err == nil {
+				return path, nil
+			}
+			return fields[1], nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s: module path not found", filename)
+}
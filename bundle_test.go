@@ -0,0 +1,94 @@
+package minformat
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	sources := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.18\n",
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/m/a"
+	. "example.com/m/b"
+	_ "example.com/m/c"
+)
+
+var x = a.F(1)
+
+func main() {
+	var v a.T
+	fmt.Println(x, v.T2.N, v.N, G(), a.Values)
+}
+`,
+		"a/a.go": `package a
+
+import "example.com/m/b"
+
+type T struct{ b.T2 }
+
+var Values []int
+
+func init() { Values = append(Values, 1) }
+
+func F(x int) int { return helper(x) + b.G() }
+
+func helper(x int) int { return x }
+`,
+		"a/init.go": `package a
+
+func init() { Values = append(Values, 2) }
+`,
+		"b/b.go": `package b
+
+import "strings"
+
+type T2 struct{ N int }
+
+func G() int { return len(strings.ToUpper("b")) }
+`,
+		"c/c.go": `package c
+
+import b "fmt"
+
+func init() { b.Println() }
+`,
+	}
+	dir := t.TempDir()
+	for name, src := range sources {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fset := token.NewFileSet()
+	f, err := (&Config{}).Bundle(fset, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := Node(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	want := `package main;import("strings";b"fmt");` +
+		`type b_T2 struct{N int};func b_G()int{return len(strings.ToUpper("b"))};` +
+		`type a_T struct{b_T2};var a_Values []int;func a_init0(){a_Values=append(a_Values,1)};` +
+		`func a_F(x int)int{return a_helper(x)+b_G()};func a_helper(x int)int{return x};` +
+		`func a_init1(){a_Values=append(a_Values,2)};var _=func()int{a_init0();a_init1();return 0}();` +
+		`func c_init0(){b.Println()};var _=func()int{c_init0();return 0}();` +
+		`var x=a_F(1);func main(){var v a_T;b.Println(x,v.b_T2.N,v.N,b_G(),a_Values)}`
+	if have := buf.String(); have != want {
+		t.Errorf("bundle:\nhave: %q\nwant: %q", have, want)
+	}
+}
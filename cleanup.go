@@ -0,0 +1,249 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// cleanup removes or shortens the redundant statements and blocks in f.
+//
+// Statements can only be moved to the enclosing scope
+// if that doesn't change the identifiers resolution.
+func cleanup(info *types.Info, f *ast.File) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body != nil {
+				cleanupFunc(info, n.Recv, n.Type, n.Body)
+			}
+		case *ast.FuncLit:
+			cleanupFunc(info, nil, n.Type, n.Body)
+		}
+		return true
+	})
+}
+
+func cleanupFunc(info *types.Info, recv *ast.FieldList, typ *ast.FuncType, body *ast.BlockStmt) {
+	// Receiver, parameters and results are declared in the function body scope.
+	scope := make(map[string]bool)
+	for _, list := range []*ast.FieldList{recv, typ.TypeParams, typ.Params, typ.Results} {
+		if list == nil {
+			continue
+		}
+		for _, field := range list.List {
+			for _, name := range field.Names {
+				scope[name.Name] = true
+			}
+		}
+	}
+	body.List = cleanupStmtList(info, body.List, scope)
+
+	if typ.Results == nil || len(typ.Results.List) == 0 {
+		if ret, ok := lastStmt(body.List).(*ast.ReturnStmt); ok && len(ret.Results) == 0 {
+			body.List = body.List[:len(body.List)-1]
+		}
+	}
+}
+
+// cleanupStmtList returns the cleaned up version of the list.
+// scope contains the names that are declared in the list scope outside of the list itself.
+func cleanupStmtList(info *types.Info, list []ast.Stmt, scope map[string]bool) []ast.Stmt {
+	for _, stmt := range list {
+		cleanupStmt(info, stmt)
+	}
+	for _, stmt := range list {
+		for _, name := range declaredNames(stmt) {
+			scope[name] = true
+		}
+	}
+
+	var result []ast.Stmt
+	for i, stmt := range list {
+		isLast := i == len(list)-1
+		switch stmt := stmt.(type) {
+		case *ast.EmptyStmt:
+			continue
+
+		case *ast.BlockStmt:
+			if canSplice(stmt.List, scope, isLast) {
+				result = append(result, stmt.List...)
+				continue
+			}
+
+		case *ast.IfStmt:
+			// `if c {return} else {...}` => `if c {return};...`
+			if stmt.Init != nil || stmt.Else == nil || !isTerminating(info, lastStmt(stmt.Body.List)) {
+				break
+			}
+			var els []ast.Stmt
+			switch e := stmt.Else.(type) {
+			case *ast.BlockStmt:
+				els = e.List
+			case *ast.IfStmt:
+				els = []ast.Stmt{e}
+			}
+			if canSplice(els, scope, isLast) {
+				stmt.Else = nil
+				result = append(result, stmt)
+				result = append(result, els...)
+				continue
+			}
+		}
+		result = append(result, stmt)
+	}
+	return result
+}
+
+// canSplice reports whether list can be moved to the enclosing block.
+func canSplice(list []ast.Stmt, scope map[string]bool, isLast bool) bool {
+	var names []string
+	for _, stmt := range list {
+		names = append(names, declaredNames(stmt)...)
+	}
+	if len(names) == 0 {
+		return true
+	}
+	// The declarations become visible to the statements that follow the list;
+	// there are none if the list is the last one.
+	if !isLast {
+		return false
+	}
+	for _, name := range names {
+		if scope[name] {
+			return false
+		}
+	}
+	for _, name := range names {
+		scope[name] = true
+	}
+	return true
+}
+
+func cleanupStmt(info *types.Info, n ast.Stmt) {
+	switch n := n.(type) {
+	case *ast.BlockStmt:
+		n.List = cleanupStmtList(info, n.List, make(map[string]bool))
+
+	case *ast.LabeledStmt:
+		cleanupStmt(info, n.Stmt)
+
+	case *ast.IfStmt:
+		n.Body.List = cleanupStmtList(info, n.Body.List, make(map[string]bool))
+		if n.Else != nil {
+			cleanupStmt(info, n.Else)
+		}
+		if els, ok := n.Else.(*ast.BlockStmt); ok && len(els.List) == 0 {
+			n.Else = nil
+		}
+
+	case *ast.ForStmt:
+		if isGlobalIdent(info, n.Cond, "true") {
+			n.Cond = nil
+		}
+		n.Body.List = cleanupStmtList(info, n.Body.List, make(map[string]bool))
+
+	case *ast.RangeStmt:
+		n.Body.List = cleanupStmtList(info, n.Body.List, make(map[string]bool))
+
+	case *ast.SwitchStmt:
+		if isGlobalIdent(info, n.Tag, "true") {
+			n.Tag = nil
+		}
+		for _, stmt := range n.Body.List {
+			cc := stmt.(*ast.CaseClause)
+			cc.Body = cleanupClauseBody(info, cc.Body, make(map[string]bool))
+		}
+
+	case *ast.TypeSwitchStmt:
+		// The symbolic variable is declared in every clause implicit block.
+		scope := func() map[string]bool {
+			m := make(map[string]bool)
+			if as, ok := n.Assign.(*ast.AssignStmt); ok {
+				m[as.Lhs[0].(*ast.Ident).Name] = true
+			}
+			return m
+		}
+		for _, stmt := range n.Body.List {
+			cc := stmt.(*ast.CaseClause)
+			cc.Body = cleanupClauseBody(info, cc.Body, scope())
+		}
+
+	case *ast.SelectStmt:
+		for _, stmt := range n.Body.List {
+			cc := stmt.(*ast.CommClause)
+			scope := make(map[string]bool)
+			if cc.Comm != nil {
+				for _, name := range declaredNames(cc.Comm) {
+					scope[name] = true
+				}
+			}
+			cc.Body = cleanupClauseBody(info, cc.Body, scope)
+		}
+	}
+}
+
+func cleanupClauseBody(info *types.Info, list []ast.Stmt, scope map[string]bool) []ast.Stmt {
+	list = cleanupStmtList(info, list, scope)
+	if br, ok := lastStmt(list).(*ast.BranchStmt); ok && br.Tok == token.BREAK && br.Label == nil {
+		list = list[:len(list)-1]
+	}
+	return list
+}
+
+// declaredNames returns the names that n declares in the enclosing scope.
+func declaredNames(n ast.Stmt) []string {
+	var names []string
+	switch n := n.(type) {
+	case *ast.LabeledStmt:
+		return declaredNames(n.Stmt)
+	case *ast.AssignStmt:
+		if n.Tok != token.DEFINE {
+			return nil
+		}
+		for _, lhs := range n.Lhs {
+			if ident, ok := lhs.(*ast.Ident); ok && ident.Name != "_" {
+				names = append(names, ident.Name)
+			}
+		}
+	case *ast.DeclStmt:
+		decl := n.Decl.(*ast.GenDecl)
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				for _, ident := range spec.Names {
+					if ident.Name != "_" {
+						names = append(names, ident.Name)
+					}
+				}
+			case *ast.TypeSpec:
+				names = append(names, spec.Name.Name)
+			}
+		}
+	}
+	return names
+}
+
+// isTerminating reports whether the control never flows past n.
+func isTerminating(info *types.Info, n ast.Stmt) bool {
+	switch n := n.(type) {
+	case *ast.ReturnStmt:
+		return true
+	case *ast.BranchStmt:
+		return n.Tok != token.FALLTHROUGH
+	case *ast.BlockStmt:
+		return isTerminating(info, lastStmt(n.List))
+	case *ast.ExprStmt:
+		call, ok := n.X.(*ast.CallExpr)
+		return ok && isGlobalIdent(info, call.Fun, "panic")
+	default:
+		return false
+	}
+}
+
+func lastStmt(list []ast.Stmt) ast.Stmt {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[len(list)-1]
+}
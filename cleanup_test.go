@@ -0,0 +1,100 @@
+package minformat
+
+import (
+	"testing"
+)
+
+func TestCleanup(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`f(); ; g()`, `f();g()`},
+
+		{`if c { f() } else {}`, `if c{f()}`},
+		{`if c { f() } else if d { g() } else {}`, `if c{f()}else if d{g()}`},
+		{`if c { return } else { f() }; g()`, `if c{return };f();g()`},
+		{`if c { return } else if d { f() } else { g() }`, `if c{return };if d{f()}else{g()}`},
+		{`if c { panic(1) } else { x := 1; f(x) }`, `if c{panic(1)};x:=1;f(x)`},
+		{`for { if c { break } else { f() } }`, `for{if c{break};f()}`},
+		{`for { if c { continue } else { f() }; g() }`, `for{if c{continue};f();g()}`},
+
+		// The init statement declarations are visible in else.
+		{`if x := f(); x { return } else { g(x) }`, `if x:=f();x{return }else{g(x)}`},
+		// The body is not terminating.
+		{`if c { f() } else { g() }`, `if c{f()}else{g()}`},
+		// The declaration would become visible to the following statements.
+		{`if c { return } else { x := 1; f(x) }; g()`, `if c{return }else{x:=1;f(x)};g()`},
+
+		{`{ f() }; { g(); { h() } }`, `f();g();h()`},
+		{`{}; f()`, `f()`},
+		{`{ x := 1; f(x) }`, `x:=1;f(x)`},
+		{`{ var x = 1; f(x) }`, `var x=1;f(x)`},
+		{`{ type T int; f(T(0)) }`, `type T int;f(T(0))`},
+		{`for { { f(); { g() } } }`, `for{f();g()}`},
+
+		// The block declarations would shadow the ones that follow it.
+		{`{ x := 1; f(x) }; x := 2; f(x)`, `{x:=1;f(x)};x:=2;f(x)`},
+		{`{ x := 1; f(x) }; f(x)`, `{x:=1;f(x)};f(x)`},
+		// x would conflict with a parameter.
+		{`{ a := 1; f(a) }`, `{a:=1;f(a)}`},
+		// x would conflict with an earlier declaration.
+		{`x := 1; f(x); { x := 2; f(x) }`, `x:=1;f(x);{x:=2;f(x)}`},
+		{`for x := range xs { { x := 2; f(x) } }`, `for x:=range xs{x:=2;f(x)}`},
+		{`switch x := v.(type) { case int: { x := 2; f(x) } }`, `switch x:=v.(type){case int:{x:=2;f(x)}}`},
+		{`select { case x := <-ch: { x := 2; f(x) } }`, `select{case x:=<-ch:{x:=2;f(x)}}`},
+		{`L: { f() }`, `L:{f()}`},
+
+		{`f(); return`, `f()`},
+		{`if c { return }`, `if c{return }`},
+		{`f := func() { g(); return }; f()`, `f:=func(){g()};f()`},
+
+		{`switch { case c: f(); break }`, `switch{case c:f()}`},
+		{`switch x.(type) { case int: f(); break; default: break }`, `switch x.(type){case int:f();default:}`},
+		{`select { case <-ch: f(); break }`, `select{case <-ch:f()}`},
+		{`L: switch { case c: break L }`, `L:switch{case c:break L}`},
+		{`for { switch { case c: if d { break }; f() } }`, `for{switch{case c:if d{break};f()}}`},
+
+		{`switch true { case c: f() }`, `switch{case c:f()}`},
+		{`switch x := 1; true { case x > 0: f() }`, `switch x:=1;{case x>0:f()}`},
+		{`for true { f() }`, `for{f()}`},
+		{`for ; c; { f() }`, `for c{f()}`},
+		{`for ; true; { f() }`, `for{f()}`},
+	}
+
+	cfg := &Config{Cleanup: true}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package p;func _(a int) {" + test.src + "}"))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;func _(a int){" + test.want + "}"
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestCleanupResults(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`func f() { return }`, `func f(){}`},
+		{`func (r T) f() { { r := 1; g(r) } }`, `func(r T)f(){{r:=1;g(r)}}`},
+		{`func f() (err error) { g(); return }`, `func f()(err error){g();return }`},
+		{`func f() int { if c { return 1 } else { return 2 } }`, `func f()int{if c{return 1};return 2}`},
+	}
+
+	cfg := &Config{Cleanup: true}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package p;" + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
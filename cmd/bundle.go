@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"go/token"
+	"os"
+
+	"github.com/go-toolsmith/minformat"
+)
+
+// bundleMain implements the bundle subcommand:
+//
+//	bundle dir
+//
+// It prints the minified main package located in dir with all packages
+// of the same module it imports inlined into it.
+func bundleMain(args []string) int {
+	flags := flag.NewFlagSet("bundle", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usageError(flags, "bundle needs 1 argument: main package directory")
+	}
+
+	fset := token.NewFileSet()
+	f, err := (&minformat.Config{}).Bundle(fset, flags.Arg(0))
+	if err != nil {
+		return reportError("", err)
+	}
+	if err := minformat.Node(os.Stdout, fset, f); err != nil {
+		return reportError("", err)
+	}
+	return exitOK
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	// cacheMaxAge is the time after which an unused cache entry is removed.
+	cacheMaxAge = 5 * 24 * time.Hour
+	// cachePruneInterval is how often the cache is pruned.
+	cachePruneInterval = 24 * time.Hour
+)
+
+// diskCache is a minformat.Cache that stores the entries in the files of a directory.
+//
+// The entries are addressed by the hash of the key and the command version,
+// so a new build doesn't use the results of the old one.
+type diskCache struct {
+	dir     string
+	version string
+}
+
+// openCache returns the cache located in dir, creating it if needed;
+// if dir is empty, the user cache directory is used.
+func openCache(dir string) (*diskCache, error) {
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(userDir, "minformat")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	version, err := commandVersion()
+	if err != nil {
+		return nil, err
+	}
+	return &diskCache{dir: dir, version: version}, nil
+}
+
+func (c *diskCache) filename(key string) string {
+	h := sha256.Sum256([]byte(c.version + "\x00" + key))
+	name := hex.EncodeToString(h[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+func (c *diskCache) Get(key string) ([]byte, bool) {
+	filename := c.filename(key)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, false
+	}
+	// Mark the entry as used for prune.
+	now := time.Now()
+	os.Chtimes(filename, now, now)
+	return data, true
+}
+
+func (c *diskCache) Put(key string, data []byte) {
+	filename := c.filename(key)
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return
+	}
+	// Write to a temporary file first, so the concurrent runs
+	// never see a partially written entry.
+	f, err := os.CreateTemp(filepath.Dir(filename), "tmp-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+}
+
+// prune removes the entries that were not used for cacheMaxAge.
+// It does nothing if the cache was pruned less than cachePruneInterval ago.
+func (c *diskCache) prune() error {
+	marker := filepath.Join(c.dir, "pruned")
+	now := time.Now()
+	if info, err := os.Stat(marker); err == nil && now.Sub(info.ModTime()) < cachePruneInterval {
+		return nil
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		return err
+	}
+	return filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path == marker {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if now.Sub(info.ModTime()) > cacheMaxAge {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// commandVersion returns a string that identifies the build of the command.
+//
+// The development builds are identified by the hash of the executable,
+// the versions and VCS revisions of them may be the same for different code.
+func commandVersion() (string, error) {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version := info.Main.Version
+		revision, modified := "", ""
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				revision = s.Value
+			case "vcs.modified":
+				modified = s.Value
+			}
+		}
+		if version != "" && version != "(devel)" {
+			return version, nil
+		}
+		if revision != "" && modified == "false" {
+			return revision, nil
+		}
+	}
+
+	filename, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"go/scanner"
+	"go/types"
+	"os"
+)
+
+// The exit codes of the command.
+const (
+	exitOK = 0
+	// exitInput means the input has syntax or type errors.
+	exitInput = 1
+	// exitUsage means the command line is invalid.
+	exitUsage = 2
+	// exitInternal means any other failure, like an I/O error.
+	exitInternal = 3
+	// exitDiff means the verify subcommand found differences.
+	exitDiff = 4
+)
+
+// usageError prints msg with the flags usage and exits.
+func usageError(flags *flag.FlagSet, msg string) {
+	fmt.Fprintln(os.Stderr, msg)
+	flags.Usage()
+	os.Exit(exitUsage)
+}
+
+// reportError prints err to stderr and returns the exit code for it.
+//
+// The syntax errors are printed one per line in the file:line:col: message form;
+// if filename is not empty, it replaces the file names of their positions.
+func reportError(filename string, err error) int {
+	var list scanner.ErrorList
+	if errors.As(err, &list) {
+		for _, e := range list {
+			if filename != "" {
+				e.Pos.Filename = filename
+			}
+			fmt.Fprintln(os.Stderr, e)
+		}
+		return exitInput
+	}
+	var typeErr types.Error
+	if errors.As(err, &typeErr) {
+		fmt.Fprintln(os.Stderr, typeErr)
+		return exitInput
+	}
+	fmt.Fprintln(os.Stderr, "minformat:", err)
+	return exitInternal
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/go-toolsmith/minformat"
+)
+
+// explainMain implements the explain subcommand:
+//
+//	explain [-json] [-n count] [-all] path ...
+//
+// It prints the sizes of the packages, their files and top-level declarations
+// before and after the minification, the largest ones first.
+// The files are grouped into packages by their directories and package names.
+func explainMain(args []string) int {
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the report as JSON")
+	count := flags.Int("n", 20, "print at most `count` largest declarations of every package, 0 means all")
+	all := flags.Bool("all", false, "don't skip the testdata, vendor and hidden directories and the _ and . files")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usageError(flags, "explain needs at least 1 argument: files, directories or dir/... patterns to explain")
+	}
+
+	inputs, err := collectFiles(flags.Args(), *all)
+	if err != nil {
+		return reportError("", err)
+	}
+	code := exitOK
+	fset := token.NewFileSet()
+	packages := make(map[string][]*ast.File)
+	var keys []string
+	for _, input := range inputs {
+		f, err := parser.ParseFile(fset, input.path, nil, parser.ParseComments)
+		if err != nil {
+			if c := reportError("", err); c > code {
+				code = c
+			}
+			continue
+		}
+		key := filepath.Join(filepath.Dir(input.path), f.Name.Name)
+		if packages[key] == nil {
+			keys = append(keys, key)
+		}
+		packages[key] = append(packages[key], f)
+	}
+
+	var report []*minformat.Explanation
+	for _, key := range keys {
+		e, err := (&minformat.Config{}).Explain(fset, packages[key])
+		if err != nil {
+			if c := reportError("", err); c > code {
+				code = c
+			}
+			continue
+		}
+		if *count > 0 && len(e.Decls) > *count {
+			e.Decls = e.Decls[:*count]
+		}
+		report = append(report, e)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		if err := enc.Encode(report); err != nil {
+			return reportError("", err)
+		}
+		return code
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, e := range report {
+		fmt.Fprintf(w, "package %s\toriginal\tminified\tcompressed\t\n", e.Package)
+		printSizes(w, "total", e.Total)
+		for _, f := range e.Files {
+			printSizes(w, f.File, f.Sizes)
+		}
+		for _, d := range e.Decls {
+			printSizes(w, fmt.Sprintf("%s (%s)", d.Name, d.Pos), d.Sizes)
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		return reportError("", err)
+	}
+	return code
+}
+
+func printSizes(w *tabwriter.Writer, name string, s minformat.Sizes) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t\n", name, s.Original, s.Minified, s.Compressed)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+
+	"github.com/go-toolsmith/minformat"
+)
+
+// extractMain implements the extract subcommand:
+//
+//	extract [-keep-package] package symbol
+//
+// It prints the minified symbol declaration with all its package dependencies.
+// The package is a directory or an import path.
+func extractMain(args []string) int {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	keepPackage := flags.Bool("keep-package", false, "keep the package name instead of renaming it to main")
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		usageError(flags, "extract needs 2 arguments: package and symbol")
+	}
+
+	bp, err := build.Import(flags.Arg(0), ".", 0)
+	if err != nil {
+		return reportError("", err)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range bp.GoFiles {
+		f, err := parser.ParseFile(fset, filepath.Join(bp.Dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return reportError("", err)
+		}
+		files = append(files, f)
+	}
+
+	pkgName := "main"
+	if *keepPackage {
+		pkgName = ""
+	}
+	f, err := (&minformat.Config{}).Extract(fset, files, flags.Arg(1), pkgName)
+	if err != nil {
+		return reportError("", err)
+	}
+	if err := minformat.Node(os.Stdout, fset, f); err != nil {
+		return reportError("", err)
+	}
+	return exitOK
+}
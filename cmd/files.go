@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inputFile is a Go file to be minified.
+type inputFile struct {
+	// path is the file path as it was found.
+	path string
+	// rel is the path relative to the current directory;
+	// it's used to mirror the file in the output directory.
+	rel string
+}
+
+// collectFiles expands the command line arguments to the list of Go files.
+//
+// An argument is a file, a directory or a "dir/..." pattern;
+// the directories are walked recursively. The testdata, vendor and
+// hidden directories and the files whose names start with "." or "_"
+// found by the walk are skipped unless all is set, like the go command does.
+//
+// The files outside of the current directory get their rel paths
+// relative to the parent of the argument they were found by instead.
+func collectFiles(args []string, all bool) ([]inputFile, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	var files []inputFile
+	for _, arg := range args {
+		root := arg
+		if root == "..." {
+			root = "."
+		} else if strings.HasSuffix(root, "/...") {
+			root = strings.TrimSuffix(root, "/...")
+		}
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			rel, err := relPath(wd, root, root)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, inputFile{path: root, rel: rel})
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && !all && skipDir(d.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(d.Name(), ".go") || !all && skipFile(d.Name()) {
+				return nil
+			}
+			rel, err := relPath(wd, root, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, inputFile{path: path, rel: rel})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// relPath returns the path relative to the working directory wd
+// or, if it's outside of wd, relative to the parent of root.
+func relPath(wd, root, path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(wd, abs); err == nil && filepath.IsLocal(rel) {
+		return rel, nil
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(filepath.Dir(absRoot), abs)
+}
+
+// checkOutputs returns an error if several files would be written
+// to the same path of the output directory.
+func checkOutputs(files []inputFile) error {
+	seen := make(map[string]string)
+	for _, file := range files {
+		if prev, ok := seen[file.rel]; ok {
+			return fmt.Errorf("%s and %s would both be written to %s", prev, file.path, file.rel)
+		}
+		seen[file.rel] = file.path
+	}
+	return nil
+}
+
+// skipFile reports whether the file found by the walk is skipped by default,
+// like the go command ignores it.
+func skipFile(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// skipDir reports whether the directory is skipped by default,
+// like the go command ignores it in the package patterns.
+func skipDir(name string) bool {
+	return name == "testdata" || name == "vendor" ||
+		strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
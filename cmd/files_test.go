@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCollectFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a/x.go", "a/sub/y.go", "b/x.go", "a/_z.go", "a/testdata/t.go"} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte("package p"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chdir(t, dir)
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"a/x.go"}, []string{"a/x.go"}},
+		{[]string{"a"}, []string{"a/sub/y.go", "a/x.go"}},
+		{[]string{"a/..."}, []string{"a/sub/y.go", "a/x.go"}},
+		{[]string{"a", "b"}, []string{"a/sub/y.go", "a/x.go", "b/x.go"}},
+		{[]string{"./a/sub", "a/x.go"}, []string{"a/sub/y.go", "a/x.go"}},
+	}
+	for _, test := range tests {
+		files, err := collectFiles(test.args, false)
+		if err != nil {
+			t.Fatalf("collect %v: %v", test.args, err)
+		}
+		var have []string
+		for _, file := range files {
+			have = append(have, filepath.ToSlash(file.rel))
+		}
+		if !reflect.DeepEqual(have, test.want) {
+			t.Errorf("collect %v:\nhave: %v\nwant: %v", test.args, have, test.want)
+		}
+		if err := checkOutputs(files); err != nil {
+			t.Errorf("collect %v: %v", test.args, err)
+		}
+	}
+
+	// The files outside of the current directory
+	// are placed under the argument base name.
+	chdir(t, filepath.Join(dir, "b"))
+	files, err := collectFiles([]string{filepath.Join(dir, "a")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have []string
+	for _, file := range files {
+		have = append(have, filepath.ToSlash(file.rel))
+	}
+	if want := []string{"a/sub/y.go", "a/x.go"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("collect outside:\nhave: %v\nwant: %v", have, want)
+	}
+}
+
+// chdir changes the working directory until the end of the test.
+func chdir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
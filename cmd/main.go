@@ -1,29 +1,186 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 
 	"github.com/go-toolsmith/minformat"
 )
 
 func main() {
-	if len(os.Args) != 2 {
-		panic("needs 1 argument: file to process")
+	if len(os.Args) > 1 && os.Args[1] == "extract" {
+		os.Exit(extractMain(os.Args[2:]))
 	}
+	if len(os.Args) > 1 && os.Args[1] == "bundle" {
+		os.Exit(bundleMain(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(verifyMain(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "explain" {
+		os.Exit(explainMain(os.Args[2:]))
+	}
+	os.Exit(minifyMain(os.Args[1:]))
+}
 
-	filename := os.Args[1]
+// minifyMain implements the default command:
+//
+//	[-w | -o dir] [-all] [-tokens] [-stats [-json]] [-j n] [-no-cache | -cache-dir dir] [path ...]
+//
+// It minifies the files, directories and dir/... patterns;
+// without arguments, it works as a filter from stdin to stdout.
+// With -stats, the size statistics are printed to stderr.
+// With -tokens, the files are minified token by token without parsing.
+//
+// The results of the files are cached on disk, so the unchanged files
+// are not minified again; the cache can't be used by the filter.
+func minifyMain(args []string) int {
+	flags := flag.NewFlagSet("minformat", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the source files instead of stdout")
+	outDir := flags.String("o", "", "write the results to the `dir` mirroring the source tree")
+	all := flags.Bool("all", false, "don't skip the testdata, vendor and hidden directories and the _ and . files")
+	tokens := flags.Bool("tokens", false, "minify the tokens without parsing, for huge or invalid files")
+	printStats := flags.Bool("stats", false, "print the size statistics to stderr")
+	statsJSON := flags.Bool("json", false, "print the -stats report as JSON")
+	jobs := flags.Int("j", 0, "process `n` files at once, 0 means GOMAXPROCS")
+	noCache := flags.Bool("no-cache", false, "don't use the results cache")
+	cacheDir := flags.String("cache-dir", "", "keep the results cache in `dir` instead of the user cache directory")
+	flags.Parse(args)
+	if *write && *outDir != "" {
+		usageError(flags, "-w and -o can't be used together")
+	}
+	if *statsJSON && !*printStats {
+		usageError(flags, "-json needs -stats")
+	}
+	var report *statsReport
+	if *printStats {
+		report = &statsReport{}
+		defer func() {
+			if err := report.write(os.Stderr, *statsJSON); err != nil {
+				reportError("", err)
+			}
+		}()
+	}
+
+	if flags.NArg() == 0 {
+		if *write || *outDir != "" {
+			usageError(flags, "-w and -o can't be used with the standard input")
+		}
+		if *tokens && report == nil {
+			// Both the input and the output are streamed, the input may be huge.
+			if err := minformat.TokenSource(os.Stdout, os.Stdin); err != nil {
+				return reportError("<standard input>", err)
+			}
+			return exitOK
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return reportError("", err)
+		}
+		res, err := minify(src, "<standard input>", report, *tokens)
+		if err != nil {
+			return reportError("<standard input>", err)
+		}
+		if _, err := os.Stdout.Write(res); err != nil {
+			return reportError("", err)
+		}
+		return exitOK
+	}
 
-	b, err := os.ReadFile(filename)
+	files, err := collectFiles(flags.Args(), *all)
 	if err != nil {
-		panic(err)
+		return reportError("", err)
 	}
+	if *outDir != "" {
+		if err := checkOutputs(files); err != nil {
+			usageError(flags, err.Error())
+		}
+	}
+	filenames := make([]string, len(files))
+	for i, file := range files {
+		filenames[i] = file.path
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// The files are processed even after a failure,
+	// the most severe failure determines the exit code.
+	code := exitOK
+	i := 0
+	batch := &minformat.Batch{Jobs: *jobs, Stats: report != nil, Tokens: *tokens}
+	if !*noCache {
+		// The cache is an optimization, the command works without it.
+		if cache, err := openCache(*cacheDir); err == nil {
+			batch.Cache = cache
+			defer cache.prune()
+		}
+	}
+	err = batch.Run(ctx, filenames, func(r *minformat.FileResult) error {
+		file := files[i]
+		err := r.Err
+		if err == nil {
+			if report != nil {
+				report.add(file.path, r.Stats)
+			}
+			switch {
+			case *write:
+				err = writeFile(file.path, r.Output)
+			case *outDir != "":
+				err = writeFile(filepath.Join(*outDir, file.rel), r.Output)
+			default:
+				if i != 0 {
+					os.Stdout.WriteString("\n")
+				}
+				_, err = os.Stdout.Write(r.Output)
+			}
+		}
+		if err != nil {
+			if c := reportError(file.path, err); c > code {
+				code = c
+			}
+		}
+		i++
+		return nil
+	})
+	if err != nil {
+		return reportError("", err)
+	}
+	return code
+}
 
-	res, err := minformat.Source(b)
+// minify minifies src and adds its statistics to report, if it's not nil.
+// With tokens, src is minified by minformat.TokenSourceStats.
+func minify(src []byte, filename string, report *statsReport, tokens bool) ([]byte, error) {
+	var res []byte
+	var stats *minformat.Stats
+	var err error
+	switch {
+	case tokens:
+		res, stats, err = minformat.TokenSourceStats(src)
+	case report == nil:
+		return minformat.Source(src)
+	default:
+		res, stats, err = (&minformat.Config{}).SourceStats(src)
+	}
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	report.add(filename, stats)
+	return res, nil
+}
 
-	if _, err := os.Stdout.Write(res); err != nil {
-		panic(err)
+// writeFile writes data to filename creating its directory if needed.
+// The permissions of an existing file are preserved.
+func writeFile(filename string, data []byte) error {
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
 	}
+	return os.WriteFile(filename, data, perm)
 }
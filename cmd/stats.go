@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/go-toolsmith/minformat"
+)
+
+// statsReport collects the statistics of the processed files.
+type statsReport struct {
+	Files []fileStats      `json:"files"`
+	Total *minformat.Stats `json:"total"`
+}
+
+type fileStats struct {
+	File string `json:"file"`
+	*minformat.Stats
+}
+
+func (r *statsReport) add(filename string, stats *minformat.Stats) {
+	if r.Total == nil {
+		r.Total = &minformat.Stats{}
+	}
+	r.Files = append(r.Files, fileStats{File: filename, Stats: stats})
+	r.Total.Add(stats)
+}
+
+// write prints the report as JSON or as text;
+// the text report has the total only for several files.
+func (r *statsReport) write(w io.Writer, asJSON bool) error {
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(r)
+	}
+	for _, f := range r.Files {
+		writeStats(w, f.File, f.Stats)
+	}
+	if len(r.Files) > 1 {
+		writeStats(w, "total", r.Total)
+	}
+	return nil
+}
+
+func writeStats(w io.Writer, name string, s *minformat.Stats) {
+	fmt.Fprintf(w, "%s: %d -> %d bytes (%s), gzip %d -> %d (%s), deflate %d -> %d (%s)\n",
+		name,
+		s.InputBytes, s.OutputBytes, percent(s.InputBytes, s.OutputBytes),
+		s.InputGzipBytes, s.OutputGzipBytes, percent(s.InputGzipBytes, s.OutputGzipBytes),
+		s.InputDeflateBytes, s.OutputDeflateBytes, percent(s.InputDeflateBytes, s.OutputDeflateBytes))
+	for _, saving := range s.Savings {
+		fmt.Fprintf(w, "\t%-20s %d\n", saving.Category, saving.Bytes)
+	}
+}
+
+// percent returns the size change in percents, like "-12.5%".
+// The changes that round to zero have no sign.
+func percent(before, after int) string {
+	if before == 0 {
+		return "0.0%"
+	}
+	p := fmt.Sprintf("%+.1f%%", float64(after-before)*100/float64(before))
+	if p == "+0.0%" || p == "-0.0%" {
+		return "0.0%"
+	}
+	return p
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-toolsmith/minformat"
+)
+
+// verifyMain implements the verify subcommand:
+//
+//	verify [-types] [-all] path ...
+//
+// It checks that the minified files parse to the same syntax trees
+// and that minifying them again doesn't change them.
+// With -types, it also checks that every package gets the same type information;
+// only the non-test files matching the default build context are type-checked.
+func verifyMain(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	checkTypes := flags.Bool("types", false, "also check that the minified packages type-check identically")
+	all := flags.Bool("all", false, "don't skip the testdata, vendor and hidden directories and the _ and . files")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usageError(flags, "verify needs at least 1 argument: files, directories or dir/... patterns to verify")
+	}
+
+	inputs, err := collectFiles(flags.Args(), *all)
+	if err != nil {
+		return reportError("", err)
+	}
+	code := exitOK
+	fail := func(c int) {
+		if c > code {
+			code = c
+		}
+	}
+	report := func(diffs []minformat.Diff) {
+		for _, d := range diffs {
+			fmt.Fprintln(os.Stderr, d)
+			fail(exitDiff)
+		}
+	}
+
+	fset := token.NewFileSet()
+	// packages are the files to type-check grouped by the directory and package name.
+	packages := make(map[string][]*ast.File)
+	var keys []string
+	for _, input := range inputs {
+		f, err := parser.ParseFile(fset, input.path, nil, 0)
+		if err != nil {
+			fail(reportError("", err))
+			continue
+		}
+		dir, name := filepath.Split(input.path)
+		if match, _ := build.Default.MatchFile(dir, name); *checkTypes && match && !strings.HasSuffix(name, "_test.go") {
+			key := filepath.Join(dir, f.Name.Name)
+			if packages[key] == nil {
+				keys = append(keys, key)
+			}
+			packages[key] = append(packages[key], f)
+			continue
+		}
+		diffs, err := minformat.Verify(fset, f)
+		if err != nil {
+			fail(reportError(input.path, err))
+			continue
+		}
+		report(diffs)
+	}
+	for _, key := range keys {
+		diffs, err := minformat.VerifyTypes(fset, packages[key], nil)
+		if err != nil {
+			fail(reportError("", err))
+			continue
+		}
+		report(diffs)
+	}
+	return code
+}
@@ -0,0 +1,49 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// positionalLits rewrites the keyed struct literals `T{A: a, B: b}` into `T{a, b}`.
+//
+// Only the literals of the struct types declared in the package are rewritten
+// and only if all fields are listed in the declaration order,
+// so the elements evaluation order stays the same.
+func positionalLits(tc *typeChecker, files []*ast.File) error {
+	if err := tc.check(files); err != nil {
+		return err
+	}
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.CompositeLit)
+			if !ok || len(lit.Elts) == 0 {
+				return true
+			}
+			typ := tc.info.TypeOf(lit)
+			if ptr, ok := typ.Underlying().(*types.Pointer); ok {
+				typ = ptr.Elem()
+			}
+			st, ok := typ.Underlying().(*types.Struct)
+			if !ok || st.NumFields() != len(lit.Elts) {
+				return true
+			}
+			values := make([]ast.Expr, len(lit.Elts))
+			for i, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					return true
+				}
+				key, ok := kv.Key.(*ast.Ident)
+				field := st.Field(i)
+				if !ok || key.Name != field.Name() || field.Pkg() != tc.pkg {
+					return true
+				}
+				values[i] = kv.Value
+			}
+			lit.Elts = values
+			return true
+		})
+	}
+	return nil
+}
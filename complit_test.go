@@ -0,0 +1,47 @@
+package minformat
+
+import (
+	"testing"
+)
+
+func TestPositionalLits(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`var _ = T{A: 1, B: "b"}`, `var _=T{1,"b"}`},
+		{`var _ = &T{A: 1, B: "b"}`, `var _=&T{1,"b"}`},
+		{`var _ = []T{{A: 1, B: "b"}}`, `var _=[]T{{1,"b"}}`},
+		{`var _ = []*T{{A: 1, B: "b"}}`, `var _=[]*T{{1,"b"}}`},
+		{`var _ = map[T]E{{A: 1, B: "b"}: {T: T{}, C: 2}}`, `var _=map[T]E{{1,"b"}:{T{},2}}`},
+		{`var _ = A{A: 1, B: "b"}`, `var _=A{1,"b"}`},
+		{`var _ = struct{ X, Y int }{X: 1, Y: 2}`, `var _=struct{X,Y int}{1,2}`},
+		{`var _ = G[int]{V: 1}`, `var _=G[int]{1}`},
+
+		// Not all fields.
+		{`var _ = T{A: 1}`, `var _=T{A:1}`},
+		// Not in the declaration order.
+		{`var _ = T{B: "b", A: 1}`, `var _=T{B:"b",A:1}`},
+		// Already positional.
+		{`var _ = T{1, "b"}`, `var _=T{1,"b"}`},
+		// Declared in another package.
+		{`var _ = image.Point{X: 1, Y: 2}`, `var _=image.Point{X:1,Y:2}`},
+		// Not a struct.
+		{`var _ = map[string]int{"a": 1}`, `var _=map[string]int{"a":1}`},
+	}
+
+	cfg := &Config{PositionalLits: true}
+	for _, test := range tests {
+		const header = `package p;import "image";var _ image.Point;type T struct { A int; B string };` +
+			`type E struct { T; C int };type A = T;type G[X any] struct { V X };`
+		have, err := cfg.Source([]byte(header + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := `package p;import"image";var _ image.Point;type T struct{A int;B string};` +
+			`type E struct{T;C int};type A=T;type G[X any] struct{V X};` + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
@@ -0,0 +1,464 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// elision is a tentative removal of the information the compiler can infer.
+type elision struct {
+	// node is the changed subtree.
+	node ast.Node
+
+	apply  func()
+	revert func()
+
+	// types returns the types that must stay identical after the elision.
+	types func(info *types.Info) []types.Type
+	want  []string
+}
+
+// elideTypes removes the explicit types, conversions and type arguments
+// that don't affect the program types.
+//
+// Every elision is confirmed by type-checking the package again:
+// the ones that change any type or break the compilation are reverted.
+func elideTypes(tc *typeChecker, files []*ast.File) error {
+	// Nested elisions are handled in the subsequent rounds.
+	for {
+		n, err := elideTypesRound(tc, files)
+		if err != nil || n == 0 {
+			return err
+		}
+	}
+}
+
+// elideTypesRound performs all independent elisions it can find
+// and returns the number of the applied ones.
+func elideTypesRound(tc *typeChecker, files []*ast.File) (int, error) {
+	if err := tc.check(files); err != nil {
+		return 0, err
+	}
+
+	var elisions []*elision
+	for _, f := range files {
+		parents := parentMap(f)
+		ast.Inspect(f, func(n ast.Node) bool {
+			var e *elision
+			descend := true
+			switch n := n.(type) {
+			case *ast.ValueSpec:
+				e = elideVarType(tc.info, n)
+			case *ast.CallExpr:
+				if e = elideTypeArgs(tc.info, n); e == nil {
+					// Nested conversions would replace the same node twice.
+					e = elideConversion(tc.info, n, parents)
+					descend = e == nil
+				}
+			}
+			if e != nil {
+				var ok bool
+				if e.want, ok = typeStrings(e.types(tc.info)); ok {
+					elisions = append(elisions, e)
+				}
+			}
+			return descend
+		})
+	}
+
+	for _, e := range elisions {
+		e.apply()
+	}
+	for len(elisions) != 0 {
+		var keep, drop []*elision
+		if err := tc.check(files); err != nil {
+			// Revert the elisions that caused the errors.
+			// If we can't tell which ones did, revert them all.
+			for _, e := range elisions {
+				if hasErrorInside(tc.errors, e.node) {
+					drop = append(drop, e)
+				} else {
+					keep = append(keep, e)
+				}
+			}
+			if len(drop) == 0 {
+				drop, keep = elisions, nil
+			}
+		} else {
+			for _, e := range elisions {
+				if list, ok := typeStrings(e.types(tc.info)); ok && sameStrings(list, e.want) {
+					keep = append(keep, e)
+				} else {
+					drop = append(drop, e)
+				}
+			}
+		}
+		if len(drop) == 0 {
+			break
+		}
+		for _, e := range drop {
+			e.revert()
+		}
+		elisions = keep
+	}
+
+	return len(elisions), nil
+}
+
+// elideVarType handles `var x T = v` where v already has type T.
+func elideVarType(info *types.Info, spec *ast.ValueSpec) *elision {
+	if spec.Type == nil || len(spec.Values) != len(spec.Names) {
+		return nil
+	}
+	if _, ok := info.Defs[spec.Names[0]].(*types.Var); !ok {
+		// Constants can be untyped, it's not worth the risk.
+		return nil
+	}
+	want := info.TypeOf(spec.Type)
+	for _, v := range spec.Values {
+		if !isTypedValue(info, v) || !types.Identical(info.TypeOf(v), want) {
+			return nil
+		}
+	}
+	typ := spec.Type
+	return &elision{
+		node:   spec,
+		apply:  func() { spec.Type = nil },
+		revert: func() { spec.Type = typ },
+		types: func(info *types.Info) []types.Type {
+			var list []types.Type
+			for _, name := range spec.Names {
+				if obj := info.Defs[name]; obj != nil {
+					list = append(list, obj.Type())
+				}
+			}
+			return list
+		},
+	}
+}
+
+// elideConversion handles `T(x)` where x already has type T.
+func elideConversion(info *types.Info, call *ast.CallExpr, parents map[ast.Node]ast.Node) *elision {
+	parent := parents[call]
+	if len(call.Args) != 1 || call.Ellipsis != token.NoPos || parent == nil {
+		return nil
+	}
+	if tv, ok := info.Types[call.Fun]; !ok || !tv.IsType() {
+		return nil
+	}
+	arg := call.Args[0]
+	if !isTypedValue(info, arg) || !types.Identical(info.TypeOf(arg), info.TypeOf(call)) {
+		return nil
+	}
+	if isFloatType(info.TypeOf(call)) && !isOperand(arg) {
+		// An explicit conversion rounds the result of the arithmetic
+		// and prevents the fused multiply-add.
+		return nil
+	}
+
+	var repl ast.Expr = arg
+	if needsParens(arg, call, parents) {
+		repl = &ast.ParenExpr{Lparen: arg.Pos(), X: arg, Rparen: arg.End()}
+	}
+	return &elision{
+		node:   call,
+		apply:  func() { replaceChild(parent, call, repl) },
+		revert: func() { replaceChild(parent, repl, call) },
+		types: func(info *types.Info) []types.Type {
+			if t := info.TypeOf(repl); t != nil {
+				return []types.Type{t}
+			}
+			return []types.Type{info.TypeOf(call)}
+		},
+	}
+}
+
+// elideTypeArgs handles `f[T](x)` where the type arguments can be inferred.
+func elideTypeArgs(info *types.Info, call *ast.CallExpr) *elision {
+	var fn ast.Expr
+	switch x := call.Fun.(type) {
+	case *ast.IndexExpr:
+		fn = x.X
+	case *ast.IndexListExpr:
+		fn = x.X
+	default:
+		return nil
+	}
+	var ident *ast.Ident
+	switch fn := fn.(type) {
+	case *ast.Ident:
+		ident = fn
+	case *ast.SelectorExpr:
+		ident = fn.Sel
+	default:
+		return nil
+	}
+	if _, ok := info.Uses[ident].(*types.Func); !ok {
+		return nil
+	}
+	if _, ok := info.Instances[ident]; !ok {
+		return nil
+	}
+
+	typeArgs := call.Fun
+	return &elision{
+		node:   call,
+		apply:  func() { call.Fun = fn },
+		revert: func() { call.Fun = typeArgs },
+		types: func(info *types.Info) []types.Type {
+			inst, ok := info.Instances[ident]
+			if !ok {
+				return nil
+			}
+			list := []types.Type{inst.Type}
+			for i := 0; i < inst.TypeArgs.Len(); i++ {
+				list = append(list, inst.TypeArgs.At(i))
+			}
+			return list
+		},
+	}
+}
+
+// isTypedValue reports whether n is a value with a type on its own,
+// so its type doesn't depend on the context.
+func isTypedValue(info *types.Info, n ast.Expr) bool {
+	tv, ok := info.Types[n]
+	if !ok || !tv.IsValue() || tv.IsNil() || tv.Value != nil {
+		return false
+	}
+	// Comparisons produce untyped booleans that take the type from the context.
+	if bin, ok := unparen(n).(*ast.BinaryExpr); ok {
+		switch bin.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return false
+		}
+	}
+	return true
+}
+
+// isPrimaryExpr reports whether n can be used as an operand
+// of any expression without the parentheses.
+func isPrimaryExpr(n ast.Expr) bool {
+	switch n.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.CallExpr, *ast.IndexExpr, *ast.IndexListExpr,
+		*ast.SliceExpr, *ast.TypeAssertExpr, *ast.ParenExpr:
+		return true
+	default:
+		return false
+	}
+}
+
+// needsParens reports whether x needs the parentheses to replace old.
+func needsParens(x, old ast.Expr, parents map[ast.Node]ast.Node) bool {
+	switch parent := parents[old].(type) {
+	case *ast.BinaryExpr:
+		switch x := x.(type) {
+		case *ast.BinaryExpr:
+			// The binary operators are left-associative.
+			prec, parentPrec := x.Op.Precedence(), parent.Op.Precedence()
+			if prec < parentPrec || prec == parentPrec && old == parent.Y {
+				return true
+			}
+		case *ast.UnaryExpr:
+			// The operators could merge, like `a< <-c` and `a+ +b`.
+			if old == parent.Y {
+				return true
+			}
+		}
+	case *ast.UnaryExpr, *ast.StarExpr:
+		if !isPrimaryExpr(x) && !isLiteral(x) {
+			return true
+		}
+	case *ast.SelectorExpr, *ast.SliceExpr, *ast.TypeAssertExpr:
+		// old is the operand, as the other children are not values of its type.
+		if !isPrimaryExpr(x) && !isLiteral(x) {
+			return true
+		}
+	case *ast.IndexExpr:
+		if old == parent.X && !isPrimaryExpr(x) && !isLiteral(x) {
+			return true
+		}
+	case *ast.CallExpr:
+		if old == parent.Fun && !isPrimaryExpr(x) && !isLiteral(x) {
+			return true
+		}
+	}
+	// `if x == T{} {` is parsed as `if x == T {`, unless the literal is enclosed.
+	return hasTypeNameLit(x) && inControlClause(old, parents)
+}
+
+// isLiteral reports whether n is a literal, an operand like the primary expressions.
+func isLiteral(n ast.Expr) bool {
+	switch n.(type) {
+	case *ast.BasicLit, *ast.CompositeLit, *ast.FuncLit:
+		return true
+	default:
+		return false
+	}
+}
+
+// hasTypeNameLit reports whether n contains a composite literal
+// whose type is a type name outside of the parentheses.
+func hasTypeNameLit(n ast.Expr) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.ParenExpr, *ast.FuncLit:
+			return false
+		case *ast.CompositeLit:
+			typ := n.Type
+			switch t := typ.(type) {
+			case *ast.IndexExpr:
+				typ = t.X
+			case *ast.IndexListExpr:
+				typ = t.X
+			}
+			switch typ.(type) {
+			case *ast.Ident, *ast.SelectorExpr:
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// inControlClause reports whether n is in the header of an if, for or switch statement
+// where the composite literals of the named types need the parentheses.
+func inControlClause(n ast.Node, parents map[ast.Node]ast.Node) bool {
+	for p := parents[n]; p != nil; n, p = p, parents[p] {
+		switch p := p.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt:
+			// The bodies are blocks, so n is a part of the header.
+			return true
+		case *ast.BlockStmt, *ast.ParenExpr, *ast.CompositeLit:
+			return false
+		case *ast.CallExpr:
+			if n != p.Fun {
+				return false
+			}
+		case *ast.IndexExpr:
+			if n != p.X {
+				return false
+			}
+		case *ast.IndexListExpr:
+			if n != p.X {
+				return false
+			}
+		}
+	}
+	return false
+}
+
+// isFloatType reports whether the underlying type of t is a float or a complex.
+func isFloatType(t types.Type) bool {
+	basic, ok := t.Underlying().(*types.Basic)
+	return ok && basic.Info()&(types.IsFloat|types.IsComplex) != 0
+}
+
+// isOperand reports whether n is an identifier, a selector, a call or a literal,
+// so its value is not the result of the arithmetic.
+func isOperand(n ast.Expr) bool {
+	switch unparen(n).(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.CallExpr, *ast.BasicLit, *ast.CompositeLit, *ast.FuncLit:
+		return true
+	default:
+		return false
+	}
+}
+
+func hasErrorInside(errors []types.Error, n ast.Node) bool {
+	for _, err := range errors {
+		if n.Pos() <= err.Pos && err.Pos < n.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// typeStrings returns the representations of the types that are stable
+// across several type-checker runs. The function-local named types are
+// printed like the package-level ones they may shadow, so their
+// representations can't be compared and ok is false if list has them.
+func typeStrings(list []types.Type) (strs []string, ok bool) {
+	for _, t := range list {
+		if hasLocalType(t) {
+			return nil, false
+		}
+		s := ""
+		if t != nil {
+			s = types.TypeString(t, nil)
+		}
+		strs = append(strs, s)
+	}
+	return strs, true
+}
+
+// hasLocalType reports whether t refers to a named type declared in a function.
+func hasLocalType(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Named:
+		if obj := t.Obj(); obj.Pkg() != nil && obj.Parent() != obj.Pkg().Scope() {
+			return true
+		}
+		for i := 0; i < t.TypeArgs().Len(); i++ {
+			if hasLocalType(t.TypeArgs().At(i)) {
+				return true
+			}
+		}
+	case *types.Alias:
+		if obj := t.Obj(); obj.Pkg() != nil && obj.Parent() != obj.Pkg().Scope() {
+			return true
+		}
+		return hasLocalType(types.Unalias(t))
+	case *types.Pointer:
+		return hasLocalType(t.Elem())
+	case *types.Slice:
+		return hasLocalType(t.Elem())
+	case *types.Array:
+		return hasLocalType(t.Elem())
+	case *types.Chan:
+		return hasLocalType(t.Elem())
+	case *types.Map:
+		return hasLocalType(t.Key()) || hasLocalType(t.Elem())
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if hasLocalType(t.At(i).Type()) {
+				return true
+			}
+		}
+	case *types.Signature:
+		return hasLocalType(t.Params()) || hasLocalType(t.Results())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if hasLocalType(t.Field(i).Type()) {
+				return true
+			}
+		}
+	case *types.Interface:
+		for i := 0; i < t.NumMethods(); i++ {
+			if hasLocalType(t.Method(i).Type()) {
+				return true
+			}
+		}
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			if hasLocalType(t.EmbeddedType(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sameStrings(x, y []string) bool {
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
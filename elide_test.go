@@ -0,0 +1,79 @@
+package minformat
+
+import (
+	"testing"
+)
+
+func TestElideTypes(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`var x int = y`, `var x=y`},
+		{`var x, z int = y, y`, `var x,z=y,y`},
+		{`var x T = T{}`, `var x=T{}`},
+		{`var x T = g()`, `var x=g()`},
+		{`var x = int(y)`, `var x=y`},
+		{`var x = T(T{})`, `var x=T{}`},
+		{`var x = int(y + y)`, `var x=y+y`},
+		{`var x = int(y + y) * 2`, `var x=(y+y)*2`},
+		{`var x = int(y * y) + 2`, `var x=y*y+2`},
+		{`var x = y - int(y - y)`, `var x=y-(y-y)`},
+		{`var x = -int(y + y)`, `var x=-(y+y)`},
+		{`func f(c chan int) bool { return y < int(<-c) }`, `func f(c chan int)bool{return y<(<-c)}`},
+		// The composite literals in the control clauses need the parentheses.
+		{`func f() { if T(T{}) == (T{}) { _ = T(T{}) } }`, `func f(){if (T{})==(T{}){_=T{}}}`},
+		{`func f() { if g() == T(T{}) {} }`, `func f(){if g()==(T{}){}}`},
+		{`func f() { for _, v := range []T{T(T{})} { _ = v } }`, `func f(){for _,v:=range []T{T{}}{_=v}}`},
+		{`var x = int(int(y))`, `var x=y`},
+		{`var x = []byte(b)`, `var x=b`},
+		{`var x = id[int](y)`, `var x=id(y)`},
+		{`var x = pair[int, string](y, "")`, `var x=pair(y,"")`},
+
+		// Untyped constants take the type from the context.
+		{`var x int64 = 1`, `var x int64=1`},
+		{`var x = int64(1)`, `var x=int64(1)`},
+		{`const c = 1; var x int64 = c`, `const c=1;var x int64=c`},
+		{`var x B = y == y`, `var x B=y==y`},
+		{`var x = B(y == y)`, `var x=B(y==y)`},
+		{`var x error = nil`, `var x error=nil`},
+		{`var x = id[int64](1)`, `var x=id[int64](1)`},
+		// Different types.
+		{`var x I = T{}`, `var x I=T{}`},
+		{`var x = int64(y)`, `var x=int64(y)`},
+		{`var x = I(T{})`, `var x=I(T{})`},
+		{`var x = id[I](T{})`, `var x=id[I](T{})`},
+		// Float conversions round the result and prevent the fused multiply-add.
+		{`var x = float64(fl*fl) + fl`, `var x=float64(fl*fl)+fl`},
+		{`var x = complex128(cx * cx)`, `var x=complex128(cx*cx)`},
+		{`var x = float64(fl) * fl`, `var x=fl*fl`},
+		// Constants are not affected.
+		{`const x int = 1`, `const x int=1`},
+		// Not a call, the type arguments can't be inferred.
+		{`var x = id[int]`, `var x=id[int]`},
+		// A local type is printed like the package-level one it shadows.
+		{
+			`type U int;func (U) String() string {return "U!"};var u U;func f() any {type U int;z := U(u);return z}`,
+			`type U int;func(U)String()string{return "U!"};var u U;func f()any{type U int;z:=U(u);return z}`,
+		},
+		{
+			`func f() {type L int;var l L;var x L = l;_ = x}`,
+			`func f(){type L int;var l L;var x L=l;_=x}`,
+		},
+	}
+
+	cfg := &Config{ElideTypes: true}
+	for _, test := range tests {
+		const header = "package p;type T struct{};type I interface{};type B bool;" +
+			"var y int;var fl float64;var cx complex128;var b []byte;func g() T;func id[X any](x X) X {return x};func pair[X, Y any](x X, y Y) X {return x};"
+		have, err := cfg.Source([]byte(header + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;type T struct{};type I interface{};type B bool;" +
+			"var y int;var fl float64;var cx complex128;var b []byte;func g()T;func id[X any](x X)X{return x};func pair[X,Y any](x X,y Y)X{return x};" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
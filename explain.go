@@ -0,0 +1,199 @@
+package minformat
+
+import (
+	"bytes"
+	"compress/flate"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Sizes are the sizes of a part of the code in bytes.
+type Sizes struct {
+	Original int `json:"original"`
+	Minified int `json:"minified"`
+
+	// Compressed is the contribution to the deflate-compressed minified file:
+	// the number of the compressed bytes produced for the part when it's written
+	// to the compressor that already got everything before it and then flushed.
+	//
+	// Every flush ends a deflate block, so the contributions include the block
+	// overhead and add up to more than the compressed file size.
+	Compressed int `json:"compressed"`
+}
+
+func (s *Sizes) add(other Sizes) {
+	s.Original += other.Original
+	s.Minified += other.Minified
+	s.Compressed += other.Compressed
+}
+
+// Explanation is a report of the sizes of a package declarations, see Explain.
+type Explanation struct {
+	Package string `json:"package"`
+	Total   Sizes  `json:"total"`
+
+	// Files are the package files sorted by the minified size, the largest first.
+	Files []FileSizes `json:"files"`
+
+	// Decls are the top-level declarations of all files
+	// sorted by the minified size, the largest first.
+	Decls []DeclSizes `json:"decls"`
+}
+
+// FileSizes are the sizes of a file; they include the package clause
+// and the comments that don't belong to any declaration.
+type FileSizes struct {
+	File string `json:"file"`
+	Sizes
+}
+
+// DeclSizes are the sizes of a top-level declaration.
+type DeclSizes struct {
+	// Name describes the declaration, like "func T.M" or "var x, y".
+	Name string         `json:"name"`
+	Pos  token.Position `json:"pos"`
+	Sizes
+}
+
+// Explain applies the transformations enabled by cfg to the files of a single package
+// and reports the sizes of every top-level declaration before and after the minification.
+//
+// The original sizes are taken from the source positions and include the doc comments.
+// The declarations removed by the transformations have zero minified size,
+// the ones they merge are attributed to the declaration they're merged into
+// and the new ones have zero original size.
+func (cfg *Config) Explain(fset *token.FileSet, files []*ast.File) (*Explanation, error) {
+	e := &Explanation{}
+	if len(files) != 0 {
+		e.Package = files[0].Name.Name
+	}
+	// decls maps the original declarations to their indexes in e.Decls.
+	decls := make(map[ast.Decl]int)
+	fileIndex := make(map[*ast.File]int)
+	for _, f := range files {
+		file := fset.File(f.Pos())
+		fileIndex[f] = len(e.Files)
+		e.Files = append(e.Files, FileSizes{
+			File:  file.Name(),
+			Sizes: Sizes{Original: file.Size()},
+		})
+		for _, decl := range f.Decls {
+			start := decl.Pos()
+			if doc := declDoc(decl); doc != nil {
+				start = doc.Pos()
+			}
+			decls[decl] = len(e.Decls)
+			e.Decls = append(e.Decls, DeclSizes{
+				Name:  declName(decl),
+				Pos:   fset.Position(decl.Pos()),
+				Sizes: Sizes{Original: file.Offset(decl.End()) - file.Offset(start)},
+			})
+		}
+	}
+
+	files, err := cfg.Transform(fset, files)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed countingWriter
+	w, _ := flate.NewWriter(&compressed, flate.DefaultCompression)
+	// flush compresses b after everything written to w before
+	// and returns the number of the compressed bytes it produced.
+	flush := func(b []byte) int {
+		compressed = 0
+		w.Write(b)
+		w.Flush()
+		return int(compressed)
+	}
+	var buf bytes.Buffer
+	for _, f := range files {
+		fs := &e.Files[fileIndex[f]]
+		w.Reset(&compressed)
+		buf.Reset()
+		if err := cfg.Node(&buf, fset, &ast.File{Name: f.Name}); err != nil {
+			return nil, err
+		}
+		fs.Minified = buf.Len()
+		fs.Compressed = flush(buf.Bytes())
+		for i, decl := range f.Decls {
+			buf.Reset()
+			if err := cfg.Node(&buf, fset, decl); err != nil {
+				return nil, err
+			}
+			if i != len(f.Decls)-1 {
+				buf.WriteByte(';')
+			}
+			sizes := Sizes{Minified: buf.Len(), Compressed: flush(buf.Bytes())}
+			fs.Minified += sizes.Minified
+			fs.Compressed += sizes.Compressed
+
+			j, ok := decls[decl]
+			if !ok {
+				j = len(e.Decls)
+				e.Decls = append(e.Decls, DeclSizes{Name: declName(decl), Pos: fset.Position(f.Pos())})
+			}
+			e.Decls[j].Minified += sizes.Minified
+			e.Decls[j].Compressed += sizes.Compressed
+		}
+		// The end of the stream.
+		compressed = 0
+		w.Close()
+		fs.Compressed += int(compressed)
+	}
+
+	for _, fs := range e.Files {
+		e.Total.add(fs.Sizes)
+	}
+	sort.SliceStable(e.Files, func(i, j int) bool {
+		return e.Files[i].Minified > e.Files[j].Minified
+	})
+	sort.SliceStable(e.Decls, func(i, j int) bool {
+		return e.Decls[i].Minified > e.Decls[j].Minified
+	})
+	return e, nil
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		return decl.Doc
+	case *ast.GenDecl:
+		return decl.Doc
+	}
+	return nil
+}
+
+// declName returns a short description of decl, like "func T.M" or "var x, y".
+func declName(decl ast.Decl) string {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		if decl.Recv != nil && len(decl.Recv.List) != 0 {
+			return "func " + recvBaseName(decl.Recv.List[0].Type) + "." + decl.Name.Name
+		}
+		return "func " + decl.Name.Name
+	case *ast.GenDecl:
+		if decl.Tok == token.IMPORT {
+			return "import"
+		}
+		var names []string
+		for _, spec := range decl.Specs {
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, spec.Name.Name)
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					names = append(names, name.Name)
+				}
+			}
+		}
+		const maxNames = 3
+		if len(names) > maxNames {
+			names = append(names[:maxNames], "...")
+		}
+		return decl.Tok.String() + " " + strings.Join(names, ", ")
+	}
+	return "bad declaration"
+}
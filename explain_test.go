@@ -0,0 +1,127 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	sources := []string{
+		`package p
+
+import "fmt"
+
+// T is a type.
+type T[K comparable] struct{ m map[K]int }
+
+func (t *T[K]) Get(k K) int { return t.m[k] }
+
+func F() { fmt.Println("F") }
+`,
+		`package p
+
+var a, b, c, d = 1, 2, 3, 4
+
+func unused() {}
+`,
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for i, src := range sources {
+		f, err := parser.ParseFile(fset, string(rune('a'+i))+".go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+
+	e, err := (&Config{RemoveUnused: true}).Explain(fset, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type decl struct {
+		name               string
+		original, minified int
+	}
+	want := []decl{
+		{`type T`, 58, 41},
+		{`func T.Get`, 45, 40},
+		{`func F`, 29, 26},
+		{`import`, 12, 12},
+		{`var a, b, c, ...`, 27, 0},
+		{`func unused`, 16, 0},
+	}
+	if len(e.Decls) != len(want) {
+		t.Fatalf("decls: have %d, want %d: %+v", len(e.Decls), len(want), e.Decls)
+	}
+	for i, d := range e.Decls {
+		have := decl{d.Name, d.Original, d.Minified}
+		if have != want[i] {
+			t.Errorf("decl %d:\nhave: %+v\nwant: %+v", i, have, want[i])
+		}
+	}
+
+	if e.Package != "p" || len(e.Files) != 2 || e.Files[0].File != "a.go" {
+		t.Errorf("files: %+v", e.Files)
+	}
+	var total Sizes
+	for _, fs := range e.Files {
+		src := sources[0]
+		if fs.File == "b.go" {
+			src = sources[1]
+		}
+		if fs.Original != len(src) {
+			t.Errorf("%s original size: have %d, want %d", fs.File, fs.Original, len(src))
+		}
+		total.add(fs.Sizes)
+	}
+	if total != e.Total {
+		t.Errorf("total: have %+v, want %+v", e.Total, total)
+	}
+	minified := 0
+	for _, d := range e.Decls {
+		minified += d.Minified
+	}
+	// The package clauses and their separators.
+	minified += 2 * len("package p;")
+	if minified != e.Total.Minified {
+		t.Errorf("total minified size: have %d, want %d", e.Total.Minified, minified)
+	}
+
+	// The compressed contributions add up to the compressed size of the files
+	// and the overhead of the blocks the flushes end.
+	files = files[:0]
+	for i, src := range sources {
+		f, err := parser.ParseFile(fset, string(rune('a'+i))+".go", src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	cfg := &Config{RemoveUnused: true}
+	files, err = cfg.Transform(fset, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed := 0
+	for _, f := range files {
+		var buf strings.Builder
+		if err := cfg.Node(&buf, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		_, size := compressedSizes([]byte(buf.String()))
+		compressed += size
+	}
+	if e.Total.Compressed < compressed {
+		t.Errorf("total compressed size: have %d, want at least %d", e.Total.Compressed, compressed)
+	}
+	for _, d := range e.Decls {
+		if (d.Minified != 0) != (d.Compressed > 0) {
+			t.Errorf("%s: minified size %d, compressed %d", d.Name, d.Minified, d.Compressed)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package minformat
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Extract returns a single-file package that contains the declaration of symbol
+// and all package-level declarations it depends on, transformed by cfg.
+//
+// files are the files of a single package, they may be modified.
+// symbol is a package-level name or a method name in the "T.m" form.
+// The result package is named pkgName; if it's empty, the original name is kept.
+// The main package gets an empty main function if it has none.
+//
+// The imported packages remain imports; the imports of the files
+// are merged like Package does and the unused ones are removed.
+// The result must not depend on the module of the files, so it's an error
+// if it needs a package of the same module; Bundle can inline those.
+func (cfg *Config) Extract(fset *token.FileSet, files []*ast.File, symbol, pkgName string) (*ast.File, error) {
+	tc := newTypeChecker(cfg, fset)
+	if cfg.BuildContext != nil {
+		files = specialize(tc, cfg.BuildContext, cfg.Importer, files)
+	}
+	if err := tc.check(files); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("extract %s: no files", symbol)
+	}
+	if pkgName == "" {
+		pkgName = tc.pkg.Name()
+	}
+
+	obj, err := lookupSymbol(tc.pkg, symbol)
+	if err != nil {
+		return nil, err
+	}
+	s := newTreeShaker(tc.info, files)
+	s.mark(s.units[originObject(obj)])
+	s.propagate()
+
+	m := newPackageMerger(tc.info, tc.pkg, files)
+	hasMain := false
+	for _, f := range files {
+		s.removeDecls(f)
+		var decls []ast.Decl
+		for _, decl := range f.Decls {
+			if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+				continue
+			}
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				hasMain = hasMain || fn.Recv == nil && fn.Name.Name == "main"
+			}
+			decls = append(decls, decl)
+		}
+		m.addFile(f, decls, false)
+	}
+	decls := m.decls()
+	if pkgName == "main" && !hasMain {
+		decls = append(decls, &ast.FuncDecl{
+			Name: &ast.Ident{Name: "main"},
+			Type: &ast.FuncType{Params: &ast.FieldList{}},
+			Body: &ast.BlockStmt{},
+		})
+	}
+
+	f, err := reparse(fset, pkgName, decls)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDotImports(tc, f); err != nil {
+		return nil, fmt.Errorf("extract %s: %w", symbol, err)
+	}
+	if err := checkModuleImports(fset, files[0], f, symbol); err != nil {
+		return nil, err
+	}
+	c := *cfg
+	c.BuildContext = nil
+	result, err := c.Transform(fset, []*ast.File{f})
+	if err != nil {
+		return nil, err
+	}
+	return result[0], nil
+}
+
+// checkModuleImports reports an error if f imports a package
+// of the module that file belongs to.
+// The files outside of any module can't have such imports.
+func checkModuleImports(fset *token.FileSet, file, f *ast.File, symbol string) error {
+	if len(f.Imports) == 0 {
+		return nil
+	}
+	modFile, err := findModFile(filepath.Dir(fset.Position(file.Package).Filename))
+	if err != nil {
+		return nil
+	}
+	modPath, err := readModulePath(modFile)
+	if err != nil {
+		return err
+	}
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err == nil && inModule(modPath, path) {
+			return fmt.Errorf("extract %s: package %s is in the same module", symbol, path)
+		}
+	}
+	return nil
+}
+
+// lookupSymbol finds the package-level object or the method named by symbol.
+func lookupSymbol(pkg *types.Package, symbol string) (types.Object, error) {
+	typeName, method := "", symbol
+	if i := strings.IndexByte(symbol, '.'); i >= 0 {
+		typeName, method = symbol[:i], symbol[i+1:]
+	}
+	if typeName == "" {
+		if obj := pkg.Scope().Lookup(symbol); obj != nil {
+			return obj, nil
+		}
+		return nil, fmt.Errorf("extract %s: symbol not found in package %s", symbol, pkg.Name())
+	}
+
+	tn, ok := pkg.Scope().Lookup(typeName).(*types.TypeName)
+	if !ok {
+		return nil, fmt.Errorf("extract %s: type %s not found in package %s", symbol, typeName, pkg.Name())
+	}
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(tn.Type()), false, pkg, method)
+	if fn, ok := obj.(*types.Func); ok {
+		return fn, nil
+	}
+	return nil, fmt.Errorf("extract %s: method not found in package %s", symbol, pkg.Name())
+}
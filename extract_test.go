@@ -0,0 +1,181 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	sources := []string{
+		`package p
+import ("fmt"; "strings")
+func F() { g(); fmt.Println() }
+func g() { var _ t; h() }
+func h() {}
+func Unused() { strings.ToUpper("") }
+func init() { h() }
+type t struct { u }
+type u int
+func (u) M() { h() }
+func (u) m() {}
+`,
+		`package p
+import ("fmt"; str "strings"; . "math")
+type T int
+func (T) M() int { return k + MaxInt8 }
+func (T) m() { fmt.Println(str.ToUpper("")) }
+const k = 1
+var V = fmt.Sprint(1)
+`,
+	}
+
+	tests := []struct {
+		symbol  string
+		pkgName string
+		want    string
+	}{
+		{`F`, `main`, `package main;import"fmt";func F(){g();fmt.Println()};func g(){var _ t;h()};func h(){};type t struct{u};type u int;func(u)M(){h()};func main(){}`},
+		{`h`, ``, `package p;func h(){}`},
+		{`T.M`, `p`, `package p;import."math";type T int;func(T)M()int{return k+MaxInt8};const k=1`},
+		{`T.m`, `p`, `package p;import("fmt";str"strings";."math");type T int;func(T)M()int{return k+MaxInt8};func(T)m(){fmt.Println(str.ToUpper(""))};const k=1`},
+		{`V`, `p`, `package p;import"fmt";var V=fmt.Sprint(1)`},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		var files []*ast.File
+		for i, src := range sources {
+			f, err := parser.ParseFile(fset, "p"+string(rune('0'+i))+".go", src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, f)
+		}
+		f, err := (&Config{}).Extract(fset, files, test.symbol, test.pkgName)
+		if err != nil {
+			t.Fatalf("extract %s: %v", test.symbol, err)
+		}
+		var buf strings.Builder
+		if err := Node(&buf, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		if have := buf.String(); have != test.want {
+			t.Errorf("extract %s:\nhave: %q\nwant: %q", test.symbol, have, test.want)
+		}
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	sources := []string{
+		`package p; type T int`,
+	}
+
+	tests := []struct {
+		symbol string
+		err    string
+	}{
+		{`X`, `extract X: symbol not found in package p`},
+		{`X.m`, `extract X.m: type X not found in package p`},
+		{`T.m`, `extract T.m: method not found in package p`},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		var files []*ast.File
+		for i, src := range sources {
+			f, err := parser.ParseFile(fset, "p"+string(rune('0'+i))+".go", src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, f)
+		}
+		_, err := (&Config{}).Extract(fset, files, test.symbol, "")
+		if err == nil || err.Error() != test.err {
+			t.Errorf("extract %s:\nhave error: %v\nwant error: %s", test.symbol, err, test.err)
+		}
+	}
+}
+
+func TestExtractImportConflict(t *testing.T) {
+	sources := []string{
+		`package p; import "math/rand"; func F() { rand.Int(); G() }`,
+		`package p; import "crypto/rand"; func G() { rand.Read(nil) }`,
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for i, src := range sources {
+		f, err := parser.ParseFile(fset, "p"+string(rune('0'+i))+".go", src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	f, err := (&Config{}).Extract(fset, files, "F", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	if err := Node(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+	want := `package p;import("math/rand";a"crypto/rand");func F(){rand.Int();G()};func G(){a.Read(nil)}`
+	if have := buf.String(); have != want {
+		t.Errorf("extract F:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestExtractModuleImport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/m\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	dep, err := parser.ParseFile(fset, "b.go", `package b; func G() int { return 1 }`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := (&types.Config{}).Check("example.com/m/b", fset, []*ast.File{dep}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+		if path == pkg.Path() {
+			return pkg, nil
+		}
+		return importer.Default().Import(path)
+	})}
+
+	tests := []struct {
+		symbol string
+		err    string
+	}{
+		{`F`, ``},
+		{`G`, `extract G: package example.com/m/b is in the same module`},
+	}
+
+	for _, test := range tests {
+		src := `package p; import ("fmt"; "example.com/m/b"); func F() { fmt.Println() }; func G() int { return b.G() }`
+		f, err := parser.ParseFile(fset, filepath.Join(dir, "p.go"), src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := ""
+		if _, err := cfg.Extract(fset, []*ast.File{f}, test.symbol, ""); err != nil {
+			have = err.Error()
+		}
+		if have != test.err {
+			t.Errorf("extract %s:\nhave error: %s\nwant error: %s", test.symbol, have, test.err)
+		}
+	}
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
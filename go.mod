@@ -1,9 +1,7 @@
 module github.com/go-toolsmith/minformat
 
-go 1.15
+go 1.22
 
-require (
-	github.com/go-toolsmith/astequal v1.1.0 // indirect
-	github.com/go-toolsmith/strparse v1.1.0
-	github.com/google/go-cmp v0.6.0
-)
+require github.com/go-toolsmith/strparse v1.1.0
+
+require github.com/go-toolsmith/astequal v1.1.0 // indirect
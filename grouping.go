@@ -0,0 +1,156 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// groupDecls merges the consecutive declarations of the same kind
+// into parenthesized groups and removes the parentheses around single specs.
+//
+// All imports of f are merged into a single group.
+func groupDecls(info *types.Info, f *ast.File) {
+	var decls []ast.Decl
+	var imports *ast.GenDecl
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok {
+			decls = append(decls, decl)
+			continue
+		}
+		if gd.Tok == token.IMPORT && !isCgoImport(gd) {
+			if imports == nil {
+				imports = gd
+				decls = append(decls, gd)
+			} else {
+				imports.Specs = append(imports.Specs, gd.Specs...)
+			}
+			continue
+		}
+		if prev, ok := lastDecl(decls).(*ast.GenDecl); ok && canJoinDecls(info, prev, gd) {
+			prev.Specs = append(prev.Specs, gd.Specs...)
+			continue
+		}
+		decls = append(decls, gd)
+	}
+	f.Decls = decls
+
+	for _, decl := range f.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok {
+			setDeclParens(gd)
+		}
+	}
+
+	rewrite(f, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			n.List = groupDeclStmts(info, n.List)
+		case *ast.CaseClause:
+			n.Body = groupDeclStmts(info, n.Body)
+		case *ast.CommClause:
+			n.Body = groupDeclStmts(info, n.Body)
+		}
+		return n
+	})
+}
+
+// groupDeclStmts merges the consecutive local declarations.
+//
+// Every declared name scope starts after its spec, so the grouping
+// doesn't change what the names refer to.
+func groupDeclStmts(info *types.Info, list []ast.Stmt) []ast.Stmt {
+	var result []ast.Stmt
+	var prev *ast.GenDecl
+	for _, stmt := range list {
+		ds, ok := stmt.(*ast.DeclStmt)
+		if !ok {
+			prev = nil
+			result = append(result, stmt)
+			continue
+		}
+		gd := ds.Decl.(*ast.GenDecl)
+		if prev != nil && canJoinDecls(info, prev, gd) {
+			prev.Specs = append(prev.Specs, gd.Specs...)
+			setDeclParens(prev)
+			continue
+		}
+		setDeclParens(gd)
+		prev = gd
+		result = append(result, stmt)
+	}
+	return result
+}
+
+// canJoinDecls reports whether decl specs can be appended to the group.
+//
+// iota is the spec index inside its group, so the constants that use it
+// can't be moved into another group. The implicit repetition of the previous
+// spec is not affected: every group starts with an explicit spec.
+func canJoinDecls(info *types.Info, group, decl *ast.GenDecl) bool {
+	if group.Tok != decl.Tok {
+		return false
+	}
+	switch decl.Tok {
+	case token.VAR, token.TYPE:
+		return true
+	case token.CONST:
+		return !usesIota(info, decl)
+	default:
+		return false
+	}
+}
+
+func usesIota(info *types.Info, decl *ast.GenDecl) bool {
+	found := false
+	for _, spec := range decl.Specs {
+		for _, v := range spec.(*ast.ValueSpec).Values {
+			ast.Inspect(v, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok && isGlobalIdent(info, ident, "iota") {
+					found = true
+				}
+				return !found
+			})
+		}
+	}
+	return found
+}
+
+// isCgoImport reports whether decl imports "C".
+// It must stay a separate declaration.
+func isCgoImport(decl *ast.GenDecl) bool {
+	for _, spec := range decl.Specs {
+		if spec.(*ast.ImportSpec).Path.Value == `"C"` {
+			return true
+		}
+	}
+	return false
+}
+
+// setDeclParens makes decl parenthesized only if it has several specs.
+//
+// The parentheses added to a merged decl don't appear in the source,
+// so their positions are made up: the minifier only checks whether
+// Lparen and Rparen are valid, it never uses them to place the comments
+// or the line breaks, like go/printer would.
+func setDeclParens(decl *ast.GenDecl) {
+	if len(decl.Specs) == 1 {
+		decl.Lparen = token.NoPos
+		decl.Rparen = token.NoPos
+		return
+	}
+	if decl.Lparen == token.NoPos {
+		decl.Lparen = decl.TokPos + 1
+		decl.Rparen = decl.End()
+		if decl.Rparen < decl.Lparen {
+			decl.Rparen = decl.Lparen
+		}
+	}
+}
+
+func lastDecl(list []ast.Decl) ast.Decl {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[len(list)-1]
+}
@@ -0,0 +1,50 @@
+package minformat
+
+import (
+	"testing"
+)
+
+func TestGroupDecls(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`import "a"; import "b"`, `import("a";"b")`},
+		{`import ("a"); import b "b"; import ("c"; "d")`, `import("a";b"b";"c";"d")`},
+		{`import ("a")`, `import"a"`},
+		{`import (x "a")`, `import x"a"`},
+		{`import "C"; import "a"; import "b"`, `import"C";import("a";"b")`},
+
+		{`var a = 1; var b = 2`, `var(a=1;b=2)`},
+		{`var (a = 1); var (b, c = 2, 3; d int)`, `var(a=1;b,c=2,3;d int)`},
+		{`var a = 1; func f() {}; var b = 2`, `var a=1;func f(){};var b=2`},
+		{`var (a = 1)`, `var a=1`},
+		{`type A int; type B = A; type C struct{}`, `type(A int;B=A;C struct{})`},
+		{`var a = 1; type A int`, `var a=1;type A int`},
+
+		{`const a = 1; const b = 2`, `const(a=1;b=2)`},
+		{`const (a = iota; b); const c = 10`, `const(a=iota;b;c=10)`},
+		{`const a = 1; const (b = iota; c)`, `const a=1;const(b=iota;c)`},
+		{`const (a = iota; b); const (c = iota; d)`, `const(a=iota;b);const(c=iota;d)`},
+		{`const (a = 1; b); const c = 2`, `const(a=1;b;c=2)`},
+		{`const (a = iota)`, `const a=iota`},
+
+		{`func f() { var a = 1; var b = a; g(a, b) }`, `func f(){var(a=1;b=a);g(a,b)}`},
+		{`func f() { type T int; var a T; g(a) }`, `func f(){type T int;var a T;g(a)}`},
+		{`func f() { var a = 1; g(a); var b = 2; g(b) }`, `func f(){var a=1;g(a);var b=2;g(b)}`},
+		{`func f() { const a = iota; const b = iota }`, `func f(){const a=iota;const b=iota}`},
+		{`func f() { switch { default: var a int; var b int; g(a, b) } }`, `func f(){switch{default:var(a int;b int);g(a,b)}}`},
+	}
+
+	cfg := &Config{GroupDecls: true}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package p;" + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hoistStrings replaces the repeated string literals with package-level constants
+// when that makes the package smaller.
+//
+// A literal and an untyped constant of the same value are interchangeable,
+// except for the import paths and struct tags that must be literals.
+//
+// The constants go to a file that is a part of every build of the package:
+// a non-test file without build constraints and _GOOS_GOARCH suffixes.
+// The literals of the other packages (external tests) and of the package
+// that has no such file are hoisted per file instead.
+// Build constraints are only available if the files were parsed with parser.ParseComments.
+func hoistStrings(fset *token.FileSet, files []*ast.File) {
+	if len(files) == 0 {
+		return
+	}
+
+	// Literals that can't be replaced.
+	fixed := make(map[*ast.BasicLit]bool)
+	usedNames := make(map[string]bool)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ImportSpec:
+				fixed[n.Path] = true
+			case *ast.Field:
+				if n.Tag != nil {
+					fixed[n.Tag] = true
+				}
+			case *ast.Ident:
+				usedNames[n.Name] = true
+			}
+			return true
+		})
+	}
+
+	var shared *ast.File
+	for _, f := range files {
+		if isUnconstrainedFile(fset, f) {
+			shared = f
+			break
+		}
+	}
+	names := newNameGenerator(usedNames)
+	var group []*ast.File
+	for _, f := range files {
+		if shared != nil && f.Name.Name == shared.Name.Name {
+			group = append(group, f)
+		} else {
+			hoistFileStrings(f, []*ast.File{f}, fixed, names)
+		}
+	}
+	if shared != nil {
+		hoistFileStrings(shared, group, fixed, names)
+	}
+}
+
+// hoistFileStrings hoists the repeated string literals of files
+// into the constants declared in target.
+func hoistFileStrings(target *ast.File, files []*ast.File, fixed map[*ast.BasicLit]bool, names *nameGenerator) {
+	type literal struct {
+		value string // The shortest source form
+		count int
+	}
+	literals := make(map[string]*literal)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING || fixed[lit] {
+				return true
+			}
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return true
+			}
+			l := literals[s]
+			if l == nil {
+				l = &literal{value: lit.Value}
+				literals[s] = l
+			}
+			if len(lit.Value) < len(l.value) {
+				l.value = lit.Value
+			}
+			l.count++
+			return true
+		})
+	}
+
+	// The most profitable literals get the shortest names.
+	var candidates []string
+	for s, l := range literals {
+		if l.count > 1 {
+			candidates = append(candidates, s)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		x, y := literals[candidates[i]], literals[candidates[j]]
+		if (x.count-1)*len(x.value) != (y.count-1)*len(y.value) {
+			return (x.count-1)*len(x.value) > (y.count-1)*len(y.value)
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	consts := make(map[string]string)
+	decl := &ast.GenDecl{Tok: token.CONST}
+	saved := 0
+	taken := 0
+	for _, s := range candidates {
+		l := literals[s]
+		name := names.peek()
+		// The literal occurrences are replaced with the name
+		// and the `name=literal;` spec is added.
+		before := l.count * len(l.value)
+		after := l.count*len(name) + len(name) + len(l.value) + 2
+		if after >= before {
+			continue
+		}
+		saved += before - after
+		consts[s] = name
+		decl.Specs = append(decl.Specs, &ast.ValueSpec{
+			Names:  []*ast.Ident{{Name: name}},
+			Values: []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: l.value}},
+		})
+		names.next()
+		taken++
+	}
+	// The specs above include their separators,
+	// so only the keyword and the parentheses are left.
+	overhead := len("const ")
+	if len(decl.Specs) > 1 {
+		overhead = len("const()")
+	}
+	if len(decl.Specs) == 0 || saved <= overhead {
+		// Give the names back for the other files.
+		names.rewind(taken)
+		return
+	}
+
+	for _, f := range files {
+		rewrite(f, func(n ast.Node) ast.Node {
+			lit, ok := n.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING || fixed[lit] {
+				return n
+			}
+			s, err := strconv.Unquote(lit.Value)
+			if name, ok := consts[s]; ok && err == nil {
+				return &ast.Ident{Name: name, NamePos: lit.Pos()}
+			}
+			return n
+		})
+	}
+
+	// The constants go right after the imports.
+	i := 0
+	for i < len(target.Decls) {
+		if gd, ok := target.Decls[i].(*ast.GenDecl); !ok || gd.Tok != token.IMPORT {
+			break
+		}
+		i++
+	}
+	decl.TokPos = target.Name.End()
+	setDeclParens(decl)
+	target.Decls = append(target.Decls[:i], append([]ast.Decl{decl}, target.Decls[i:]...)...)
+}
+
+// isUnconstrainedFile reports whether f is a part of the package in every build:
+// it's not a test or cgo file and it has no build constraints
+// or _GOOS_GOARCH file name suffixes.
+func isUnconstrainedFile(fset *token.FileSet, f *ast.File) bool {
+	filename := fset.File(f.Pos()).Name()
+	if strings.HasSuffix(filename, "_test.go") {
+		return false
+	}
+	for _, spec := range f.Imports {
+		if spec.Path.Value == `"C"` {
+			return false
+		}
+	}
+	for _, cg := range f.Comments {
+		if cg.Pos() >= f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if constraint.IsGoBuild(c.Text) || constraint.IsPlusBuild(c.Text) {
+				return false
+			}
+		}
+	}
+	// Any GOOS or GOARCH suffix excludes the file from one of these builds.
+	for _, ctxt := range []build.Context{
+		{GOOS: "linux", GOARCH: "amd64", Compiler: "gc"},
+		{GOOS: "windows", GOARCH: "arm64", Compiler: "gc"},
+	} {
+		if !matchFile(&ctxt, fset, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// nameGenerator produces the shortest unexported identifiers
+// that are not used anywhere in the package.
+// The names of the predeclared objects and the init and main functions are never produced.
+type nameGenerator struct {
+	used  map[string]bool
+	index int
+	name  string
+}
+
+func newNameGenerator(used map[string]bool) *nameGenerator {
+	g := &nameGenerator{used: used, index: -1}
+	g.next()
+	return g
+}
+
+// peek returns the name that the next call to next will return.
+func (g *nameGenerator) peek() string { return g.name }
+
+func (g *nameGenerator) next() string {
+	name := g.name
+	for {
+		g.index++
+		g.name = identByIndex(g.index)
+		if g.isFree(g.name) {
+			break
+		}
+	}
+	return name
+}
+
+// rewind makes the generator produce again the last n names returned by next.
+func (g *nameGenerator) rewind(n int) {
+	for ; n > 0; n-- {
+		for {
+			g.index--
+			g.name = identByIndex(g.index)
+			if g.isFree(g.name) {
+				break
+			}
+		}
+	}
+}
+
+func (g *nameGenerator) isFree(name string) bool {
+	switch {
+	case g.used[name], token.Lookup(name) != token.IDENT, types.Universe.Lookup(name) != nil:
+		return false
+	case name == "init", name == "main", name == "_":
+		return false
+	}
+	return true
+}
+
+// identByIndex returns a lowercase identifier for the index.
+// The first 26 identifiers are the single letters.
+func identByIndex(i int) string {
+	const first = "abcdefghijklmnopqrstuvwxyz"
+	const rest = first + "0123456789_"
+	name := []byte{first[i%len(first)]}
+	i /= len(first)
+	for i > 0 {
+		i--
+		name = append(name, rest[i%len(rest)])
+		i /= len(rest)
+	}
+	return string(name)
+}
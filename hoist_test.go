@@ -0,0 +1,136 @@
+package minformat
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestHoistStrings(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			`var x, y, z = "hello world", "hello world", "hello world"`,
+			`const a="hello world";var x,y,z=a,a,a`,
+		},
+		{
+			"var x, y, z = \"hello world\", `hello world`, \"hello world\"",
+			`const a="hello world";var x,y,z=a,a,a`,
+		},
+		{
+			`import "fmt"; func f() { fmt.Println("long string"); fmt.Println("long string") }`,
+			`import"fmt";const a="long string";func f(){fmt.Println(a);fmt.Println(a)}`,
+		},
+		{
+			`var a, b = "long string", "long string"; var c, d = "other string", "other string"`,
+			`const(e="other string";f="long string");var a,b=f,f;var c,d=e,e`,
+		},
+		{
+			`const c = "long string"; var x = "long string" + c`,
+			`const a="long string";const c=a;var x=a+c`,
+		},
+
+		// Not profitable.
+		{`var x, y = "ab", "ab"`, `var x,y="ab","ab"`},
+		{`var x = "hello world"`, `var x="hello world"`},
+		{`var x, y = "abcdef", "abcdef"`, `var x,y="abcdef","abcdef"`},
+
+		// Must be literals.
+		{
+			`import "encoding/json"; type T struct { x int "encoding/json"; y int "encoding/json" }; var _ = json.Marshal`,
+			`import"encoding/json";type T struct{x int"encoding/json";y int"encoding/json"};var _=json.Marshal`,
+		},
+	}
+
+	cfg := &Config{HoistStrings: true}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package p;" + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestHoistStringsFiles(t *testing.T) {
+	type file struct {
+		name string
+		src  string
+		want string
+	}
+	tests := [][]file{
+		{
+			{"a_test.go", `package p;var x, y = "hello world", "hello world"`, `package p;var x,y=a,a`},
+			{"b_linux.go", `package p;var z = "hello world"`, `package p;var z=a`},
+			{"c.go", "//go:build go1.1\n\npackage p", `package p;`},
+			{"d.go", `package p;var w = "hello world"`, `package p;const a="hello world";var w=a`},
+		},
+		{
+			{"a_linux.go", `package p;var x, y = "hello world", "hello world"`, `package p;const a="hello world";var x,y=a,a`},
+			{"a_windows.go", `package p;var x, y = "hello world", "hello world"`, `package p;const b="hello world";var x,y=b,b`},
+		},
+		{
+			{"a.go", `package p;var x, y = "hello world", "hello world"`, `package p;const b="hello world";var x,y=b,b`},
+			{"a_test.go", `package p_test;var x, y = "hello world", "hello world"`, `package p_test;const a="hello world";var x,y=a,a`},
+		},
+	}
+
+	cfg := &Config{HoistStrings: true}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		var files []*ast.File
+		for _, f := range test {
+			parsed, err := parser.ParseFile(fset, f.name, f.src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, parsed)
+		}
+		files, err := cfg.Transform(fset, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, f := range files {
+			var buf bytes.Buffer
+			if err := cfg.Node(&buf, fset, f); err != nil {
+				t.Fatal(err)
+			}
+			if have := buf.String(); have != test[i].want {
+				t.Errorf("minify %s:\nhave: %q\nwant: %q", test[i].name, have, test[i].want)
+			}
+		}
+	}
+}
+
+func TestNameGenerator(t *testing.T) {
+	g := newNameGenerator(map[string]bool{"a": true})
+	if name := g.next(); name != "b" {
+		t.Errorf("first name: have %q, want %q", name, "b")
+	}
+	for _, name := range []string{"a", "_", "init", "main", "len", "int", "nil", "if"} {
+		if g.isFree(name) {
+			t.Errorf("%q must not be produced", name)
+		}
+	}
+}
+
+func TestIdentByIndex(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 5000; i++ {
+		name := identByIndex(i)
+		if seen[name] {
+			t.Fatalf("duplicated name %q for index %d", name, i)
+		}
+		seen[name] = true
+	}
+	if identByIndex(0) != "a" || identByIndex(25) != "z" || len(identByIndex(26)) != 2 {
+		t.Errorf("unexpected names order")
+	}
+}
@@ -0,0 +1,274 @@
+package minformat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// Package merges the files of a single package into one file
+// after applying the transformations enabled by cfg.
+//
+// files should be in the order the compiler gets them, sorted by the file names.
+// The declarations keep that order, so the package variables and init functions
+// are initialized in the same order as before the merge.
+//
+// The imports of the files are merged: every package is imported once if that's possible
+// and the conflicting import names are renamed. The files that use cgo can't be merged,
+// neither can the files that dot-import packages exporting the same names.
+func (cfg *Config) Package(fset *token.FileSet, files []*ast.File) (*ast.File, error) {
+	files, err := cfg.Transform(fset, files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("package: no files")
+	}
+	for _, f := range files {
+		for _, spec := range f.Imports {
+			if spec.Path.Value == `"C"` {
+				return nil, errors.New("package: cgo files can't be merged")
+			}
+		}
+	}
+	tc := newTypeChecker(cfg, fset)
+	if err := tc.check(files); err != nil {
+		return nil, err
+	}
+
+	m := newPackageMerger(tc.info, tc.pkg, files)
+	for _, f := range files {
+		var decls []ast.Decl
+		for _, decl := range f.Decls {
+			if gd, ok := decl.(*ast.GenDecl); !ok || gd.Tok != token.IMPORT {
+				decls = append(decls, decl)
+			}
+		}
+		m.addFile(f, decls, true)
+	}
+	f, err := reparse(fset, files[0].Name.Name, m.decls())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDotImports(tc, f); err != nil {
+		return nil, fmt.Errorf("package: %w", err)
+	}
+	return f, nil
+}
+
+// packageMerger merges the declarations of several files of a package
+// and unifies their imports.
+type packageMerger struct {
+	info *types.Info
+	pkg  *types.Package
+
+	// globals are the names that are referenced as the package-level
+	// or predeclared objects in any file.
+	globals map[string]bool
+	names   *nameGenerator
+
+	// imports maps the import names of the result to the paths.
+	imports map[string]string
+	// blank are the paths of the blank imports.
+	blank []string
+	specs []ast.Spec
+
+	list []ast.Decl
+}
+
+func newPackageMerger(info *types.Info, pkg *types.Package, files []*ast.File) *packageMerger {
+	m := &packageMerger{
+		info:    info,
+		pkg:     pkg,
+		globals: make(map[string]bool),
+		imports: make(map[string]string),
+	}
+	used := make(map[string]bool)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			used[ident.Name] = true
+			obj := info.Uses[ident]
+			if obj == nil {
+				obj = info.Defs[ident]
+			}
+			if obj != nil && (obj.Parent() == types.Universe || obj.Parent() == pkg.Scope()) {
+				m.globals[ident.Name] = true
+			}
+			return true
+		})
+	}
+	m.names = newNameGenerator(used)
+	return m
+}
+
+// addFile adds decls of f to the result together with the imports they use.
+// The blank imports are only added if keepBlank is set.
+//
+// The references to the packages imported under the conflicting names are renamed.
+func (m *packageMerger) addFile(f *ast.File, decls []ast.Decl, keepBlank bool) {
+	m.list = append(m.list, decls...)
+
+	// The names of the local objects can shadow the imports.
+	locals := make(map[string]bool)
+	nodes := make([]ast.Node, len(decls))
+	for i, decl := range decls {
+		nodes[i] = decl
+		ast.Inspect(decl, func(n ast.Node) bool {
+			ident, ok := n.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			obj := m.info.Uses[ident]
+			if obj == nil {
+				obj = m.info.Defs[ident]
+			}
+			if obj != nil && obj.Parent() != nil && obj.Parent() != types.Universe && obj.Parent() != m.pkg.Scope() {
+				locals[ident.Name] = true
+			}
+			return true
+		})
+	}
+
+	uses := collectImportUses(m.info, nodes...)
+	for _, spec := range f.Imports {
+		pkgName, ok := importObject(m.info, spec).(*types.PkgName)
+		if !ok {
+			continue
+		}
+		path, _ := strconv.Unquote(spec.Path.Value)
+		switch {
+		case pkgName.Name() == "_":
+			if keepBlank {
+				m.blank = append(m.blank, path)
+			}
+		case !uses.isUsed(pkgName):
+		case pkgName.Name() == ".":
+			if m.imports[". "+path] == "" {
+				m.imports[". "+path] = path
+				m.specs = append(m.specs, &ast.ImportSpec{Name: &ast.Ident{Name: "."}, Path: spec.Path})
+			}
+		default:
+			name := m.importName(pkgName, path, locals)
+			if name != pkgName.Name() {
+				for _, n := range nodes {
+					ast.Inspect(n, func(n ast.Node) bool {
+						if ident, ok := n.(*ast.Ident); ok && m.info.Uses[ident] == pkgName {
+							ident.Name = name
+						}
+						return true
+					})
+				}
+			}
+			if m.imports[name] == "" {
+				m.imports[name] = path
+				spec := &ast.ImportSpec{Path: spec.Path}
+				if name != pkgName.Imported().Name() {
+					spec.Name = &ast.Ident{Name: name}
+				}
+				m.specs = append(m.specs, spec)
+			}
+		}
+	}
+}
+
+// importName returns the name under which the package imported as pkgName
+// from path is visible in the result.
+func (m *packageMerger) importName(pkgName *types.PkgName, path string, locals map[string]bool) string {
+	canUse := func(name string) bool {
+		if m.imports[name] != "" {
+			return m.imports[name] == path
+		}
+		// A new name in the merged file scope
+		// must not hide the package-level and predeclared objects.
+		return !m.globals[name]
+	}
+
+	// Prefer the name the package already has in the result.
+	for _, spec := range m.specs {
+		spec := spec.(*ast.ImportSpec)
+		if p, _ := strconv.Unquote(spec.Path.Value); p != path {
+			continue
+		}
+		name := pkgName.Imported().Name()
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name != "." && (name == pkgName.Name() || !locals[name]) {
+			return name
+		}
+	}
+	if canUse(pkgName.Name()) {
+		return pkgName.Name()
+	}
+	// The generated names are not used anywhere.
+	return m.names.next()
+}
+
+// decls returns the merged declarations with the imports declaration first.
+func (m *packageMerger) decls() []ast.Decl {
+	specs := m.specs
+	imported := make(map[string]bool)
+	for _, path := range m.imports {
+		imported[path] = true
+	}
+	for _, path := range m.blank {
+		if !imported[path] {
+			imported[path] = true
+			specs = append(specs, &ast.ImportSpec{
+				Name: &ast.Ident{Name: "_"},
+				Path: &ast.BasicLit{Kind: token.STRING, Value: strconv.Quote(path)},
+			})
+		}
+	}
+	if len(specs) == 0 {
+		return m.list
+	}
+	imports := &ast.GenDecl{Tok: token.IMPORT, Specs: specs}
+	setDeclParens(imports)
+	return append([]ast.Decl{imports}, m.list...)
+}
+
+// checkDotImports returns an error if the dot imports of the merged file f
+// conflict: the packages dot-imported by different files may export the same names,
+// but a file scope can only have one of them.
+func checkDotImports(tc *typeChecker, f *ast.File) error {
+	dots := make(map[token.Pos]bool)
+	for _, spec := range f.Imports {
+		if spec.Name != nil && spec.Name.Name == "." {
+			dots[spec.Name.Pos()] = true
+		}
+	}
+	if len(dots) < 2 {
+		return nil
+	}
+	// The other errors don't matter here, the files type-checked before the merge.
+	tc.check([]*ast.File{f})
+	for _, err := range tc.errors {
+		if dots[err.Pos] {
+			return err
+		}
+	}
+	return nil
+}
+
+// reparse prints decls as a file of the package pkgName and parses it again.
+//
+// The declarations that come from different files have inconsistent positions,
+// the parsed file is a proper one.
+func reparse(fset *token.FileSet, pkgName string, decls []ast.Decl) (*ast.File, error) {
+	var buf bytes.Buffer
+	var m minifier
+	if err := m.Fprint(&buf, fset, &ast.File{Name: &ast.Ident{Name: pkgName}, Decls: decls}); err != nil {
+		return nil, err
+	}
+	return parser.ParseFile(fset, pkgName+".go", buf.Bytes(), 0)
+}
@@ -0,0 +1,118 @@
+package minformat
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestPackage(t *testing.T) {
+	tests := []struct {
+		sources []string
+		want    string
+	}{
+		{
+			[]string{
+				`package p; import "fmt"; var a = f(); func init() { fmt.Println(1) }`,
+				`package p; import "fmt"; func f() int { return 0 }; func init() { fmt.Println(2) }`,
+			},
+			`package p;import"fmt";var a=f();func init(){fmt.Println(1)};func f()int{return 0};func init(){fmt.Println(2)}`,
+		},
+		{
+			[]string{
+				`package p; import "strings"; var a = strings.ToUpper`,
+				`package p; import str "strings"; var b = str.ToLower`,
+			},
+			`package p;import"strings";var a=strings.ToUpper;var b=strings.ToLower`,
+		},
+		{
+			// strings would be shadowed by the local variable.
+			[]string{
+				`package p; import "strings"; var a = strings.ToUpper`,
+				`package p; import str "strings"; func f(strings string) string { return str.ToLower(strings) }`,
+			},
+			`package p;import("strings";str"strings");var a=strings.ToUpper;func f(strings string)string{return str.ToLower(strings)}`,
+		},
+		{
+			[]string{
+				`package p; import "math/rand"; var a = rand.Int`,
+				`package p; import "crypto/rand"; var b = rand.Read`,
+				`package p; import r "crypto/rand"; var c = r.Int`,
+			},
+			`package p;import("math/rand";d"crypto/rand");var a=rand.Int;var b=d.Read;var c=d.Int`,
+		},
+		{
+			// The import would hide the predeclared len.
+			[]string{
+				`package p; import len "strings"; var a = len.ToUpper`,
+				`package p; var b = len("")`,
+			},
+			`package p;import c"strings";var a=c.ToUpper;var b=len("")`,
+		},
+		{
+			[]string{
+				`package p; import (_ "embed"; _ "image/png"); import "image"; var a image.Image`,
+				`package p; import (_ "embed"; . "image"); var b Image`,
+			},
+			`package p;import("image";."image";_"embed";_"image/png");var a image.Image;var b Image`,
+		},
+	}
+
+	for i, test := range tests {
+		fset := token.NewFileSet()
+		var files []*ast.File
+		for j, src := range test.sources {
+			f, err := parser.ParseFile(fset, fmt.Sprintf("p%d.go", j), src, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, f)
+		}
+		f, err := (&Config{}).Package(fset, files)
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		var buf strings.Builder
+		if err := Node(&buf, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		if have := buf.String(); have != test.want {
+			t.Errorf("test %d:\nhave: %q\nwant: %q", i, have, test.want)
+		}
+	}
+}
+
+func TestPackageCgo(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "p.go", `package p; import "C"; var _ C.int`, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = (&Config{}).Package(fset, []*ast.File{f})
+	if err == nil || err.Error() != "package: cgo files can't be merged" {
+		t.Errorf("have error: %v\nwant error: package: cgo files can't be merged", err)
+	}
+}
+
+func TestPackageDotImports(t *testing.T) {
+	sources := []string{
+		`package p; import . "strings"; var a = NewReader("")`,
+		`package p; import . "bytes"; var b = NewReader(nil)`,
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for i, src := range sources {
+		f, err := parser.ParseFile(fset, fmt.Sprintf("p%d.go", i), src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	_, err := (&Config{}).Package(fset, files)
+	if err == nil || !strings.Contains(err.Error(), "redeclared") {
+		t.Errorf("have error: %v\nwant the redeclaration error", err)
+	}
+}
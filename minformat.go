@@ -3,11 +3,151 @@ package minformat
 
 import (
 	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"go/version"
 	"io"
 )
 
+// Config controls the minification.
+//
+// The zero Config only removes the whitespace and comments,
+// all other transformations are opt-in.
+type Config struct {
+	// BuildContext, if not nil, specializes the package for the given build context.
+	//
+	// Files excluded by the build constraints or by the _GOOS_GOARCH file name suffixes
+	// are dropped; runtime.GOOS and runtime.GOARCH comparisons are resolved statically,
+	// so the branches that become dead are removed.
+	// The imports only the removed code used are turned into blank imports;
+	// their package names are looked up with Importer or, if it's nil, in the build context.
+	BuildContext *build.Context
+
+	// Cleanup removes or shortens the statements and blocks that do nothing:
+	// empty else branches, else after a terminating statement, nested blocks,
+	// trailing return in functions without results, trailing break in case clauses,
+	// `switch true` and `for true` conditions.
+	//
+	// The nested blocks are only removed if that doesn't affect the scoping.
+	Cleanup bool
+
+	// StripCalls removes the call statements of the functions and methods
+	// matching the patterns, like debug logging and assertions.
+	// A pattern is a qualified function name "path.F" or method name "path.T.M",
+	// for example "log.Printf" or "log.Logger.Printf";
+	// the functions and methods of the package itself can omit the path.
+	//
+	// The calls are only removed if they have no side effects, see StripSideEffects.
+	// The imports that become unused are turned into blank imports.
+	// This transformation requires the type information.
+	StripCalls []string
+
+	// StripSideEffects allows StripCalls to remove the calls
+	// whose arguments or receivers have side effects.
+	StripSideEffects bool
+
+	// RemoveUnused removes the unexported package-level functions, methods,
+	// types, vars and consts that are not reachable from the exported API,
+	// init and main functions, go:linkname and cgo export directives and
+	// the declarations of the _test.go files.
+	//
+	// The methods that may be needed to implement an interface are kept
+	// together with their types, as well as the vars initializers with side effects.
+	// The declarations referenced only from assembly can't be detected.
+	// This transformation requires the type information.
+	RemoveUnused bool
+
+	// ShortenStmts rewrites the function body statements into their shorter forms:
+	// `var x = v` into `x := v`, `x = x + y` into `x += y` and `x += 1` into `x++`.
+	//
+	// The statements are only rewritten if that doesn't change
+	// the evaluation order and the types involved.
+	// This transformation requires the type information.
+	ShortenStmts bool
+
+	// ElideTypes removes the type information the compiler can infer:
+	// explicit types in `var x T = v` where v already has type T,
+	// conversions `T(x)` where x already has type T and type arguments
+	// of generic function calls that are inferred to the same types.
+	//
+	// Every removal is confirmed by type-checking the package again.
+	// This transformation requires the type information.
+	ElideTypes bool
+
+	// GoVersion is the target Go language version, like "go1.18".
+	// It's used by the transformations that depend on the language features;
+	// ModuleGoVersion reads it from a go.mod file.
+	GoVersion string
+
+	// RewriteAny replaces the empty interface types with any if GoVersion is go1.18 or later;
+	// for the older versions, it replaces any with the empty interface instead.
+	//
+	// Only the places where any refers to the predeclared type are rewritten.
+	// This transformation requires GoVersion and the type information.
+	RewriteAny bool
+
+	// CompactSignatures shortens the signatures of functions, function literals,
+	// interface methods and function types: the consecutive parameters of the same type
+	// are merged, the parameter names are dropped if none of them are referenced
+	// and the result names are dropped if they are never referenced, even by a bare return.
+	CompactSignatures bool
+
+	// NormalizeTags rewrites the struct tags into their shortest form:
+	// the key:"value" pairs are separated by a single space
+	// and the shortest quoting is used for the tag and its values.
+	//
+	// The reflect.StructTag.Get results stay the same, but the tag strings change.
+	// The tags that don't follow the reflect.StructTag convention are left as is
+	// and passed to Report.
+	NormalizeTags bool
+
+	// PositionalLits rewrites the keyed struct literals `T{A: a, B: b}` into `T{a, b}`
+	// when all fields are listed in the declaration order.
+	// Only the struct types declared in the package are affected.
+	// This transformation requires the type information.
+	PositionalLits bool
+
+	// HoistStrings replaces the string literals that occur several times
+	// with package-level constants when that makes the package smaller.
+	// Import paths and struct tags are left as is.
+	//
+	// The constants are declared in a non-test file without build constraints,
+	// so every build of the package has them; if there is no such file,
+	// each file gets its own constants.
+	HoistStrings bool
+
+	// GroupDecls merges the consecutive var, type and const declarations
+	// into parenthesized groups, merges all imports of a file into one group
+	// and removes the parentheses around the groups that have a single spec.
+	//
+	// Constants that use iota are never moved to another group.
+	GroupDecls bool
+
+	// Skeleton prints only the package API: the function declarations are printed
+	// without bodies and Transform removes the unexported declarations
+	// the exported ones don't need together with the imports only the bodies use.
+	// Transform requires the type information in this mode.
+	Skeleton bool
+
+	// SkeletonStubs replaces the function bodies omitted by Skeleton
+	// with the shortest stubs, so the result compiles.
+	SkeletonStubs bool
+
+	// Importer resolves the imports for the transformations that need the type information.
+	// If nil, the packages are imported from source.
+	Importer types.Importer
+
+	// Report, if not nil, is called for the problems that made
+	// the transformations leave some code as is.
+	Report func(pos token.Position, msg string)
+}
+
+var defaultConfig = &Config{}
+
 // Node formats node by removing as much whitespace as possible and writes the result to w.
 //
 // Result contains no comments.
@@ -18,22 +158,195 @@ import (
 // The function may return early (before the entire result is written) and return a formatting error,
 // for instance due to an incorrect AST.
 func Node(w io.Writer, fset *token.FileSet, node interface{}) error {
-	var m minifier
-	m.Fprint(w, fset, node)
-	return nil
+	return defaultConfig.Node(w, fset, node)
 }
 
 // Source formats src by removing as mych whitespace as possible and returns the result.
 //
 // src is expected to be a syntactically correct Go source file.
 func Source(src []byte) ([]byte, error) {
-	const parserMode = 0
+	return defaultConfig.Source(src)
+}
+
+// Node is like the package-level Node function, but it uses cfg printing options.
+//
+// Node doesn't apply AST transformations, see Transform.
+func (cfg *Config) Node(w io.Writer, fset *token.FileSet, node interface{}) error {
+	m := minifier{skeleton: cfg.Skeleton, stubs: cfg.SkeletonStubs}
+	return m.Fprint(w, fset, node)
+}
+
+// Minifier formats the nodes like Node, but it keeps no per-call state
+// and writes to the caller-provided memory, so the formatting doesn't allocate.
+//
+// The zero Minifier uses the default printing options.
+// A Minifier must not be used concurrently.
+type Minifier struct {
+	m minifier
+}
+
+// NewMinifier returns a Minifier that uses cfg printing options.
+func NewMinifier(cfg *Config) *Minifier {
+	var mf Minifier
+	mf.Reset(cfg)
+	return &mf
+}
+
+// Reset makes mf use cfg printing options; nil cfg means the default ones.
+func (mf *Minifier) Reset(cfg *Config) {
+	if cfg == nil {
+		cfg = defaultConfig
+	}
+	mf.m = minifier{skeleton: cfg.Skeleton, stubs: cfg.SkeletonStubs}
+}
+
+// AppendNode appends the formatted node to dst and returns the extended slice.
+//
+// Like Node, it may stop early and return a formatting error;
+// the result then contains the part of the output written before the error.
+func (mf *Minifier) AppendNode(dst []byte, fset *token.FileSet, node interface{}) ([]byte, error) {
+	return mf.m.appendNode(dst, fset, node)
+}
+
+// Source is like the package-level Source function,
+// but it also applies the transformations enabled by cfg.
+//
+// src is treated as a single-file package.
+// If the transformations exclude the file (see BuildContext), the result is empty.
+func (cfg *Config) Source(src []byte) ([]byte, error) {
+	parserMode := parser.Mode(0)
+	if cfg.needComments() {
+		parserMode |= parser.ParseComments
+	}
 	fset := token.NewFileSet()
-	f, err := parser.ParseFile(fset, "source-input", src, parserMode)
+	f, err := parser.ParseFile(fset, "source-input.go", src, parserMode)
 	if err != nil {
 		return nil, err
 	}
+	files, err := cfg.Transform(fset, []*ast.File{f})
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
 	var buf bytes.Buffer
-	err = Node(&buf, fset, f)
+	err = cfg.Node(&buf, fset, files[0])
 	return buf.Bytes(), err
 }
+
+// Transform applies the transformations enabled by cfg to the files of a single package.
+//
+// The files are modified in place; the returned slice contains the files
+// that remain a part of the package and it may share the storage with files.
+// Some transformations need the comments, so files should be parsed with parser.ParseComments.
+func (cfg *Config) Transform(fset *token.FileSet, files []*ast.File) ([]*ast.File, error) {
+	return cfg.transform(fset, files, func(string, []*ast.File) {})
+}
+
+// transform implements Transform; done is called after every enabled
+// transformation with the name of the Config field that enables it.
+func (cfg *Config) transform(fset *token.FileSet, files []*ast.File, done func(name string, files []*ast.File)) ([]*ast.File, error) {
+	tc := newTypeChecker(cfg, fset)
+	if cfg.BuildContext != nil {
+		files = specialize(tc, cfg.BuildContext, cfg.Importer, files)
+		done("BuildContext", files)
+	}
+	if cfg.Cleanup {
+		info := tc.resolve(files)
+		for _, f := range files {
+			cleanup(info, f)
+		}
+		done("Cleanup", files)
+	}
+	if cfg.CompactSignatures {
+		info := tc.resolve(files)
+		for _, f := range files {
+			compactSignatures(info, f)
+		}
+		done("CompactSignatures", files)
+	}
+	if cfg.NormalizeTags {
+		for _, f := range files {
+			normalizeTags(f, func(pos token.Pos, msg string) {
+				cfg.report(fset, pos, msg)
+			})
+		}
+		done("NormalizeTags", files)
+	}
+
+	if len(cfg.StripCalls) != 0 {
+		if err := stripCalls(tc, files, cfg.StripCalls, cfg.StripSideEffects); err != nil {
+			return nil, err
+		}
+		done("StripCalls", files)
+	}
+	if cfg.RemoveUnused {
+		if err := removeUnused(tc, files); err != nil {
+			return nil, err
+		}
+		done("RemoveUnused", files)
+	}
+	if cfg.Skeleton {
+		if err := removeNonAPI(tc, files); err != nil {
+			return nil, err
+		}
+		done("Skeleton", files)
+	}
+	if cfg.ElideTypes {
+		if err := elideTypes(tc, files); err != nil {
+			return nil, err
+		}
+		done("ElideTypes", files)
+	}
+	if cfg.ShortenStmts {
+		if err := tc.check(files); err != nil {
+			return nil, err
+		}
+		s := shortener{info: tc.info}
+		for _, f := range files {
+			s.shortenFile(f)
+		}
+		done("ShortenStmts", files)
+	}
+	if cfg.RewriteAny {
+		v, ok := parseGoVersion(cfg.GoVersion)
+		if !ok {
+			return nil, fmt.Errorf("RewriteAny: invalid GoVersion %q", cfg.GoVersion)
+		}
+		if err := rewriteAny(tc, files, version.Compare(version.Lang(v), "go1.18") >= 0); err != nil {
+			return nil, err
+		}
+		done("RewriteAny", files)
+	}
+
+	if cfg.PositionalLits {
+		if err := positionalLits(tc, files); err != nil {
+			return nil, err
+		}
+		done("PositionalLits", files)
+	}
+	if cfg.HoistStrings {
+		hoistStrings(fset, files)
+		done("HoistStrings", files)
+	}
+	if cfg.GroupDecls {
+		info := tc.resolve(files)
+		for _, f := range files {
+			groupDecls(info, f)
+		}
+		done("GroupDecls", files)
+	}
+
+	return files, nil
+}
+
+// report passes the problem found at pos to cfg.Report, if any.
+func (cfg *Config) report(fset *token.FileSet, pos token.Pos, msg string) {
+	if cfg.Report != nil {
+		cfg.Report(fset.Position(pos), msg)
+	}
+}
+
+// needComments reports whether the enabled transformations need
+// the comments to be parsed.
+func (cfg *Config) needComments() bool {
+	return cfg.BuildContext != nil || cfg.RemoveUnused || cfg.HoistStrings
+}
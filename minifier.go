@@ -1,7 +1,6 @@
 package minformat
 
 import (
-	"bufio"
 	"fmt"
 	"go/ast"
 	"go/token"
@@ -11,17 +10,65 @@ import (
 // TODO: handle error (for invalid AST inputs)
 // TODO: `t *T` => `t*T`
 // TODO: `var x []int` => `var x[]int`
-// TODO: `import "foo"` => `import"foo"`
 
 type minifier struct {
-	out  *bufio.Writer
+	out  outBuffer
 	fset *token.FileSet
+
+	// buf keeps the Fprint output memory between the calls.
+	buf []byte
+
+	// skeleton omits the function declaration bodies.
+	skeleton bool
+	// stubs replaces the omitted bodies with the shortest ones that compile.
+	stubs bool
+}
+
+// outBuffer is the printer output. Unlike bytes.Buffer,
+// it appends to a slice that may be provided by the caller.
+type outBuffer []byte
+
+func (b *outBuffer) WriteByte(c byte) error {
+	*b = append(*b, c)
+	return nil
+}
+
+func (b *outBuffer) WriteString(s string) (int, error) {
+	*b = append(*b, s...)
+	return len(s), nil
 }
 
-func (m *minifier) Fprint(w io.Writer, fset *token.FileSet, node interface{}) {
+// formatError is a panic value used to report the nodes the printer can't handle.
+type formatError string
+
+func (e formatError) Error() string { return string(e) }
+
+func (m *minifier) Fprint(w io.Writer, fset *token.FileSet, node interface{}) error {
+	var err error
+	m.buf, err = m.appendNode(m.buf[:0], fset, node)
+	if _, werr := w.Write(m.buf); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// appendNode appends the minified node to dst.
+// If the node can't be printed, the partial result is returned with an error.
+func (m *minifier) appendNode(dst []byte, fset *token.FileSet, node interface{}) (res []byte, err error) {
 	m.fset = fset
-	m.out = bufio.NewWriter(w)
-	defer m.out.Flush()
+	m.out = dst
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(formatError)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+		res = m.out
+		// Don't keep the caller memory.
+		m.fset, m.out = nil, nil
+	}()
 
 	switch n := node.(type) {
 	case ast.Node:
@@ -29,12 +76,15 @@ func (m *minifier) Fprint(w io.Writer, fset *token.FileSet, node interface{}) {
 	default:
 		m.panicUnhandled("Fprint", n)
 	}
+	return
 }
 
 func (m *minifier) printNode(n ast.Node) {
 	switch n := n.(type) {
 	case *ast.File:
-		fmt.Fprintf(m.out, "package %s;", n.Name.Name)
+		m.out.WriteString("package ")
+		m.out.WriteString(n.Name.Name)
+		m.out.WriteByte(';')
 		for i, d := range n.Decls {
 			m.printNode(d)
 			if i != len(n.Decls)-1 {
@@ -67,8 +117,14 @@ func (m *minifier) printDecl(n ast.Decl) {
 		}
 		m.out.WriteString(n.Name.Name)
 		m.printFuncType(n.Type)
-		if n.Body != nil {
+		switch {
+		case n.Body == nil:
+		case !m.skeleton:
 			m.printBlockStmt(n.Body)
+		case m.stubs && n.Type.Results != nil:
+			m.out.WriteString("{panic(0)}")
+		case m.stubs:
+			m.out.WriteString("{}")
 		}
 
 	case *ast.GenDecl:
@@ -427,12 +483,32 @@ func (m *minifier) printBlockStmt(n *ast.BlockStmt) {
 func (m *minifier) printStmtList(list []ast.Stmt) {
 	for i, stmt := range list {
 		m.printStmt(stmt)
-		if i != len(list)-1 {
+		if i != len(list)-1 && !endsWithSemi(stmt) {
 			m.out.WriteByte(';')
 		}
 	}
 }
 
+// endsWithSemi reports whether the printed stmt already ends with a semicolon
+// that the parser consumes as a part of it or doesn't need one, so
+// another semicolon would be parsed as an extra empty statement.
+func endsWithSemi(stmt ast.Stmt) bool {
+	var body []ast.Stmt
+	switch stmt := stmt.(type) {
+	case *ast.EmptyStmt:
+		return !stmt.Implicit
+	case *ast.LabeledStmt:
+		return endsWithSemi(stmt.Stmt)
+	case *ast.CaseClause:
+		body = stmt.Body
+	case *ast.CommClause:
+		body = stmt.Body
+	default:
+		return false
+	}
+	return len(body) == 0 || endsWithSemi(body[len(body)-1])
+}
+
 func (m *minifier) printExprList(list []ast.Expr) {
 	for i, expr := range list {
 		m.printExpr(expr)
@@ -444,9 +520,12 @@ func (m *minifier) printExprList(list []ast.Expr) {
 
 func (m *minifier) printGenDecl(n *ast.GenDecl) {
 	m.out.WriteString(n.Tok.String())
-	if n.Lparen != token.NoPos {
+	switch {
+	case n.Lparen != token.NoPos:
 		m.out.WriteByte('(')
-	} else {
+	case n.Tok == token.IMPORT && !importNeedsSpace(n.Specs[0].(*ast.ImportSpec)):
+		// `import"foo"` and `import."foo"` are valid.
+	default:
 		m.out.WriteByte(' ')
 	}
 	for i, spec := range n.Specs {
@@ -617,9 +696,13 @@ func (m *minifier) printFieldList(n *ast.FieldList, sep byte) {
 func (m *minifier) panicUnhandled(fn string, n interface{}) {
 	if n, ok := n.(ast.Node); ok {
 		pos := m.fset.Position(n.Pos())
-		panic(fmt.Sprintf("%s:%d: %s: unhandled %T", pos.Filename, pos.Line, fn, n))
+		panic(formatError(fmt.Sprintf("%s:%d: %s: unhandled %T", pos.Filename, pos.Line, fn, n)))
 	}
-	panic(fmt.Sprintf("<?>: %s: unhandled %T", fn, n))
+	panic(formatError(fmt.Sprintf("<?>: %s: unhandled %T", fn, n)))
+}
+
+func importNeedsSpace(spec *ast.ImportSpec) bool {
+	return spec.Name != nil && spec.Name.Name != "."
 }
 
 func leftmostExpr(n ast.Expr) ast.Expr {
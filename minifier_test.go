@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
-	"go/format"
 	"go/parser"
 	"go/token"
 	"io/fs"
@@ -15,7 +14,6 @@ import (
 	"testing"
 
 	"github.com/go-toolsmith/strparse"
-	"github.com/google/go-cmp/cmp"
 )
 
 func TestMinifyDecl(t *testing.T) {
@@ -41,7 +39,10 @@ func TestMinifyDecl(t *testing.T) {
 		{`var x, y [ ]int = nil, nil`, `var x,y []int=nil,nil`},
 		{`var x, y [ ]int`, `var x,y []int`},
 
-		{`import "foo"`, `import "foo"`},
+		{`import "foo"`, `import"foo"`},
+		{`import . "foo"`, `import."foo"`},
+		{`import _ "foo"`, `import _"foo"`},
+		{`import a "foo"`, `import a"foo"`},
 		{`import ("foo")`, `import("foo")`},
 		{`import ("foo"; a "b")`, `import("foo";a"b")`},
 	}
@@ -64,6 +65,8 @@ func TestMinifyStmt(t *testing.T) {
 		want string
 	}{
 		{`{ ; }`, `{;}`},
+		{`{ ; f() }`, `{;f()}`},
+		{`{ L: ; f() }`, `{L:;f()}`},
 
 		{`x ++`, `x++`},
 		{`x [0 ] --`, `x[0]--`},
@@ -101,6 +104,10 @@ func TestMinifyStmt(t *testing.T) {
 		{`switch x:=10; {default: return x}`, `switch x:=10;{default:return x}`},
 		{`switch x. ( type ) {}`, `switch x.(type){}`},
 		{`switch x := v; x := x.(type) { }`, `switch x:=v;x:=x.(type){}`},
+		{`switch x {case 1:
+case 2: f()
+case 3: ;
+default:}`, `switch x{case 1:case 2:f();case 3:;default:}`},
 
 		{`for {}`, `for{}`},
 		{`for cond {}`, `for cond{}`},
@@ -123,6 +130,8 @@ func TestMinifyStmt(t *testing.T) {
 		{`select {case <-ch: return 10}`, `select{case <-ch:return 10}`},
 		{`select {case x := <-ch: return x}`, `select{case x:=<-ch:return x}`},
 		{`select {case <-ch: return 10; default: return 0}`, `select{case <-ch:return 10;default:return 0}`},
+		{`select {case <-ch:
+default: return 0}`, `select{case <-ch:default:return 0}`},
 	}
 
 	var m minifier
@@ -137,6 +146,48 @@ func TestMinifyStmt(t *testing.T) {
 	}
 }
 
+func TestMinifyEmptyStmts(t *testing.T) {
+	// A semicolon after an explicit empty statement or a clause with
+	// an empty body would be parsed as another empty statement.
+	tests := []string{
+		`func f() { ; f() }`,
+		`func f() { L: ; f() }`,
+		`func f() { ;; }`,
+		`func f() { switch { case true: case false: f() } }`,
+		`func f() { switch { case true: ; default: } }`,
+		`func f() { select { case <-ch: default: } }`,
+	}
+
+	countEmpty := func(n ast.Node) int {
+		count := 0
+		ast.Inspect(n, func(n ast.Node) bool {
+			if _, ok := n.(*ast.EmptyStmt); ok {
+				count++
+			}
+			return true
+		})
+		return count
+	}
+	for _, src := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", "package p;"+src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := Node(&buf, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		minified, err := parser.ParseFile(token.NewFileSet(), "p.go", buf.Bytes(), 0)
+		if err != nil {
+			t.Fatalf("parse minified %s: %v", src, err)
+		}
+		if have, want := countEmpty(minified), countEmpty(f); have != want {
+			t.Errorf("minify %s: %d empty statements, want %d\nresult: %s", src, have, want, buf.Bytes())
+		}
+	}
+}
+
 func TestMinifyExpr(t *testing.T) {
 	tests := []struct {
 		src  string
@@ -239,38 +290,32 @@ func TestMinifyExpr(t *testing.T) {
 }
 
 func TestGoroot(t *testing.T) {
-	var goroot string
-	{
-		out, err := exec.Command("go", "env", "GOROOT").CombinedOutput()
-		if err != nil {
-			t.Fatal(err)
-		}
-		goroot = strings.TrimSpace(string(out))
-	}
-
-	visitFile := func(filename string) error {
+	walkGoroot(t, func(filename string, src []byte) error {
 		fset := token.NewFileSet()
-		fileContents, err := os.ReadFile(filename)
+		f, err := parser.ParseFile(fset, filename, src, 0)
 		if err != nil {
 			return err
 		}
-		f, err := parser.ParseFile(fset, filename, fileContents, 0)
+		diffs, err := Verify(fset, f)
 		if err != nil {
 			return err
 		}
-		var minified bytes.Buffer
-		if err := Node(&minified, fset, f); err != nil {
-			return err
+		if len(diffs) != 0 {
+			return fmt.Errorf("minified code differs: %v", diffs)
 		}
-		fset2 := token.NewFileSet()
-		f2, err := parser.ParseFile(fset2, filename, minified.Bytes(), 0)
+		return nil
+	})
+}
+
+// walkGoroot calls visit for every Go file in GOROOT/src.
+func walkGoroot(t *testing.T, visit func(filename string, src []byte) error) {
+	var goroot string
+	{
+		out, err := exec.Command("go", "env", "GOROOT").CombinedOutput()
 		if err != nil {
-			return fmt.Errorf("re-parse minified: %w\nminified: %s", err, minified.String())
-		}
-		if diff := astDiff(f, f2); diff != "" {
-			return fmt.Errorf("minified code produced different AST:\n%s", diff)
+			t.Fatal(err)
 		}
-		return nil
+		goroot = strings.TrimSpace(string(out))
 	}
 
 	srcDir := filepath.Join(goroot, "src")
@@ -287,6 +332,7 @@ func TestGoroot(t *testing.T) {
 		// Skip some testdata dirs where not all files can be parsed.
 		// TODO(cristaloleg): skip file when we cannot parse it with go/parser.
 		if strings.Contains(path, "src/cmd/compile/internal/syntax/testdata") ||
+			strings.Contains(path, "src/cmd/cover/testdata/ranges") ||
 			strings.Contains(path, "src/cmd/compile/internal/types2/testdata") ||
 			strings.Contains(path, "src/cmd/go/internal/modindex/testdata") ||
 			strings.Contains(path, "src/cmd/go/parser/testdata") ||
@@ -296,7 +342,11 @@ func TestGoroot(t *testing.T) {
 			strings.Contains(path, "src/cmd/compile/internal/syntax/testdata/smoketest.go") {
 			return nil
 		}
-		if err := visitFile(path); err != nil {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := visit(path, src); err != nil {
 			return fmt.Errorf("%s: %w", path, err)
 		}
 		return nil
@@ -306,12 +356,85 @@ func TestGoroot(t *testing.T) {
 	}
 }
 
-func astDiff(x, y ast.Node) string {
+func TestMinifierAppendNode(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "minifier.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want bytes.Buffer
+	if err := Node(&want, fset, f); err != nil {
+		t.Fatal(err)
+	}
+
+	var mf Minifier
+	dst, err := mf.AppendNode([]byte("prefix"), fset, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst, append([]byte("prefix"), want.Bytes()...)) {
+		t.Errorf("append node:\nhave: %q\nwant: %q", dst, want.Bytes())
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		dst, _ = mf.AppendNode(dst[:0], fset, f)
+	})
+	if allocs != 0 {
+		t.Errorf("allocs per call: have %v, want 0", allocs)
+	}
+
+	if _, err := mf.AppendNode(nil, fset, &ast.BadExpr{}); err == nil {
+		t.Errorf("append bad node: no error")
+	}
+}
+
+func BenchmarkMinifierAppendNode(b *testing.B) {
+	fset, files, _ := loadBenchFiles(b)
+	var mf Minifier
+	var dst []byte
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, f := range files {
+			dst, _ = mf.AppendNode(dst[:0], fset, f)
+		}
+	}
+}
+
+func BenchmarkNode(b *testing.B) {
+	fset, files, _ := loadBenchFiles(b)
 	var buf bytes.Buffer
-	format.Node(&buf, token.NewFileSet(), x)
-	s1 := buf.String()
-	buf.Reset()
-	format.Node(&buf, token.NewFileSet(), y)
-	s2 := buf.String()
-	return cmp.Diff(s1, s2)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, f := range files {
+			buf.Reset()
+			Node(&buf, fset, f)
+		}
+	}
+}
+
+// loadBenchFiles returns the sources of the package files for the benchmarks
+// together with their parsed versions.
+func loadBenchFiles(b *testing.B) (*token.FileSet, []*ast.File, [][]byte) {
+	filenames, err := filepath.Glob("*.go")
+	if err != nil {
+		b.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	var srcs [][]byte
+	for _, filename := range filenames {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			b.Fatal(err)
+		}
+		f, err := parser.ParseFile(fset, filename, src, 0)
+		if err != nil {
+			b.Fatal(err)
+		}
+		files = append(files, f)
+		srcs = append(srcs, src)
+	}
+	return fset, files, srcs
 }
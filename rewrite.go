@@ -0,0 +1,142 @@
+package minformat
+
+import (
+	"go/ast"
+	"reflect"
+)
+
+var (
+	nodeType         = reflect.TypeOf((*ast.Node)(nil)).Elem()
+	commentGroupType = reflect.TypeOf((*ast.CommentGroup)(nil))
+)
+
+// rewrite traverses the AST rooted at root in depth-first post-order
+// and replaces every child node with the result of fn.
+//
+// Returning nil from fn removes the node from a slice (like ast.BlockStmt.List)
+// or clears the field that holds it. If the returned node can't be stored
+// in the field (e.g. an ast.Expr where *ast.Ident is expected), the field is left as is.
+//
+// The root itself is not passed to fn.
+func rewrite(root ast.Node, fn func(ast.Node) ast.Node) {
+	rewriteStruct(reflect.ValueOf(root), fn)
+}
+
+func rewriteStruct(ptr reflect.Value, fn func(ast.Node) ast.Node) {
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() || ptr.Elem().Kind() != reflect.Struct {
+		return
+	}
+	v := ptr.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch v.Type().Field(i).Name {
+		case "Imports", "Unresolved", "Comments":
+			// *ast.File duplicates: they're reachable through Decls
+			// or they are not a part of the syntax tree proper.
+			if v.Type() == reflect.TypeOf(ast.File{}) {
+				continue
+			}
+		}
+		switch f.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if f.IsNil() || !f.Type().Implements(nodeType) {
+				continue
+			}
+			if res, ok := rewriteChild(f, fn); ok {
+				f.Set(res)
+			}
+		case reflect.Slice:
+			if !f.Type().Elem().Implements(nodeType) {
+				continue
+			}
+			filtered := f.Slice(0, 0)
+			for j := 0; j < f.Len(); j++ {
+				res, ok := rewriteChild(f.Index(j), fn)
+				if !ok {
+					res = f.Index(j)
+				}
+				if res.IsValid() && !res.IsZero() {
+					filtered = reflect.Append(filtered, res)
+				}
+			}
+			f.Set(filtered)
+		}
+	}
+}
+
+// rewriteChild rewrites the node stored in v and returns a value
+// that should replace it, if the replacement is possible.
+func rewriteChild(v reflect.Value, fn func(ast.Node) ast.Node) (reflect.Value, bool) {
+	if v.IsNil() {
+		return v, false
+	}
+	node := v.Interface().(ast.Node)
+	if reflect.TypeOf(node) == commentGroupType {
+		return v, false
+	}
+	rewriteStruct(reflect.ValueOf(node), fn)
+	res := fn(node)
+	if res == nil || reflect.ValueOf(res).IsNil() {
+		return reflect.Zero(v.Type()), true
+	}
+	rv := reflect.ValueOf(res)
+	if !rv.Type().AssignableTo(v.Type()) {
+		return v, false
+	}
+	return rv, true
+}
+
+// replaceChild replaces old child of parent with repl.
+// It reports whether old was found.
+func replaceChild(parent, old, repl ast.Node) bool {
+	v := reflect.ValueOf(parent).Elem()
+	replValue := reflect.ValueOf(repl)
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if f.IsNil() || f.Interface() != old {
+				continue
+			}
+			if !replValue.Type().AssignableTo(f.Type()) {
+				return false
+			}
+			f.Set(replValue)
+			return true
+		case reflect.Slice:
+			if !f.Type().Elem().Implements(nodeType) {
+				continue
+			}
+			for j := 0; j < f.Len(); j++ {
+				elem := f.Index(j)
+				if elem.IsNil() || elem.Interface() != old {
+					continue
+				}
+				if !replValue.Type().AssignableTo(elem.Type()) {
+					return false
+				}
+				elem.Set(replValue)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parentMap returns the mapping from every node in root to its parent.
+func parentMap(root ast.Node) map[ast.Node]ast.Node {
+	parents := make(map[ast.Node]ast.Node)
+	var stack []ast.Node
+	ast.Inspect(root, func(n ast.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		if len(stack) != 0 {
+			parents[n] = stack[len(stack)-1]
+		}
+		stack = append(stack, n)
+		return true
+	})
+	return parents
+}
@@ -0,0 +1,464 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// shakeUnit is a package-level declaration that is either kept or removed as a whole.
+type shakeUnit struct {
+	// node is a *ast.FuncDecl, an ast.Spec or a *ast.GenDecl
+	// for the const groups that can't be split.
+	node ast.Node
+
+	// methods are kept if the declared type is kept.
+	methods []*shakeUnit
+
+	kept bool
+}
+
+// treeShaker finds the package-level declarations
+// that are reachable from the marked roots.
+type treeShaker struct {
+	info *types.Info
+
+	units map[types.Object]*shakeUnit
+	nodes map[ast.Node]*shakeUnit
+	queue []*shakeUnit
+
+	// skipBodies ignores the references from the function declaration bodies.
+	skipBodies bool
+}
+
+// removeUnused removes the unexported functions, methods, types, vars and consts
+// that are not reachable from the exported API, init and main functions,
+// go:linkname and cgo export directives and the _test.go files.
+//
+// The methods of the kept types are kept if they're exported or they may be needed
+// to implement an interface of the package. The vars initializers with side effects
+// are kept together with everything they reference.
+//
+// The files of each package, like the external tests, are shaken separately.
+func removeUnused(tc *typeChecker, files []*ast.File) error {
+	for _, pkgFiles := range groupByPackage(files) {
+		if allTestFiles(tc.fset, pkgFiles) {
+			// Everything the _test.go files declare is kept,
+			// so the external test packages aren't even type-checked.
+			continue
+		}
+		if err := removeUnusedPackage(tc, pkgFiles); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeUnusedPackage(tc *typeChecker, files []*ast.File) error {
+	if err := tc.check(files); err != nil {
+		return err
+	}
+	s := newTreeShaker(tc.info, files)
+
+	directives := make(map[string]bool)
+	for _, f := range files {
+		collectLinkedNames(f, directives)
+	}
+	isMain := tc.pkg.Name() == "main"
+	for _, f := range files {
+		isTest := isTestFile(tc.fset, f)
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				name := decl.Name.Name
+				switch {
+				case isTest || decl.Body == nil:
+					// The functions without body are implemented elsewhere,
+					// most likely in assembly that may reference anything.
+					s.mark(s.nodes[decl])
+				case decl.Recv == nil && (name == "_" || name == "init" || ast.IsExported(name) ||
+					name == "main" && isMain || directives[name]):
+					s.mark(s.nodes[decl])
+				}
+			case *ast.GenDecl:
+				if decl.Tok == token.IMPORT {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					u := s.nodes[spec]
+					if u == nil {
+						// The const group is a single unit.
+						u = s.nodes[decl]
+					}
+					if isTest || s.isRootSpec(spec, directives) {
+						s.mark(u)
+					}
+				}
+			}
+		}
+	}
+	s.propagate()
+
+	for _, f := range files {
+		if s.removeDecls(f) {
+			blankUnusedImports(tc.info, f)
+		}
+	}
+	return nil
+}
+
+func isTestFile(fset *token.FileSet, f *ast.File) bool {
+	return strings.HasSuffix(fset.File(f.Pos()).Name(), "_test.go")
+}
+
+func allTestFiles(fset *token.FileSet, files []*ast.File) bool {
+	for _, f := range files {
+		if !isTestFile(fset, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// newTreeShaker creates the units for the package-level declarations of files.
+//
+// The methods of a type are attached to its unit if they're exported
+// or they may be needed to implement an interface of the package.
+// Unexported methods can only implement the interfaces of their package.
+func newTreeShaker(info *types.Info, files []*ast.File) *treeShaker {
+	s := &treeShaker{
+		info:  info,
+		units: make(map[types.Object]*shakeUnit),
+		nodes: make(map[ast.Node]*shakeUnit),
+	}
+	ifaceMethods := make(map[string]bool)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if n, ok := n.(*ast.InterfaceType); ok {
+				if iface, ok := info.TypeOf(n).(*types.Interface); ok {
+					for i := 0; i < iface.NumMethods(); i++ {
+						ifaceMethods[iface.Method(i).Name()] = true
+					}
+				}
+			}
+			return true
+		})
+		for _, decl := range f.Decls {
+			s.addDecl(decl)
+		}
+	}
+
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || !ast.IsExported(fn.Name.Name) && !ifaceMethods[fn.Name.Name] {
+				continue
+			}
+			if typ := s.units[recvTypeName(info, fn)]; typ != nil {
+				typ.methods = append(typ.methods, s.nodes[fn])
+			}
+		}
+	}
+	return s
+}
+
+// propagate marks everything the marked units reference.
+func (s *treeShaker) propagate() {
+	for len(s.queue) != 0 {
+		u := s.queue[len(s.queue)-1]
+		s.queue = s.queue[:len(s.queue)-1]
+		node := u.node
+		if fn, ok := node.(*ast.FuncDecl); ok && s.skipBodies {
+			node = withoutBody(fn)
+		}
+		ast.Inspect(node, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok {
+				if dep := s.units[originObject(s.info.Uses[ident])]; dep != nil {
+					s.mark(dep)
+				}
+			}
+			return true
+		})
+		for _, m := range u.methods {
+			s.mark(m)
+		}
+	}
+}
+
+// addDecl creates the units for the package-level declaration decl.
+func (s *treeShaker) addDecl(decl ast.Decl) {
+	switch decl := decl.(type) {
+	case *ast.FuncDecl:
+		u := &shakeUnit{node: decl}
+		s.nodes[decl] = u
+		if obj := s.info.Defs[decl.Name]; obj != nil {
+			s.units[obj] = u
+		}
+	case *ast.GenDecl:
+		if decl.Tok == token.IMPORT {
+			return
+		}
+		if decl.Tok == token.CONST && !canSplitConstDecl(decl) {
+			u := &shakeUnit{node: decl}
+			s.nodes[decl] = u
+			for _, spec := range decl.Specs {
+				for _, name := range spec.(*ast.ValueSpec).Names {
+					if obj := s.info.Defs[name]; obj != nil {
+						s.units[obj] = u
+					}
+				}
+			}
+			return
+		}
+		for _, spec := range decl.Specs {
+			u := &shakeUnit{node: spec}
+			s.nodes[spec] = u
+			switch spec := spec.(type) {
+			case *ast.TypeSpec:
+				if obj := s.info.Defs[spec.Name]; obj != nil {
+					s.units[obj] = u
+				}
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					if obj := s.info.Defs[name]; obj != nil {
+						s.units[obj] = u
+					}
+				}
+			}
+		}
+	}
+}
+
+// isRootSpec reports whether spec must be kept regardless of the references to it.
+func (s *treeShaker) isRootSpec(spec ast.Spec, directives map[string]bool) bool {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return ast.IsExported(spec.Name.Name)
+	case *ast.ValueSpec:
+		for _, name := range spec.Names {
+			if name.Name == "_" || ast.IsExported(name.Name) || directives[name.Name] {
+				return true
+			}
+		}
+		for _, v := range spec.Values {
+			if !isPureExpr(s.info, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *treeShaker) mark(u *shakeUnit) {
+	if u != nil && !u.kept {
+		u.kept = true
+		s.queue = append(s.queue, u)
+	}
+}
+
+// removeDecls removes the declarations of f that are not kept
+// and reports whether any of them were removed.
+func (s *treeShaker) removeDecls(f *ast.File) bool {
+	removed := false
+	decls := f.Decls[:0]
+	for _, decl := range f.Decls {
+		if u := s.nodes[decl]; u != nil {
+			if u.kept {
+				decls = append(decls, decl)
+			} else {
+				removed = true
+			}
+			continue
+		}
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok == token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+		specs := gd.Specs[:0]
+		for _, spec := range gd.Specs {
+			if s.nodes[spec].kept {
+				specs = append(specs, spec)
+			} else {
+				removed = true
+			}
+		}
+		gd.Specs = specs
+		if len(specs) != 0 {
+			decls = append(decls, gd)
+		}
+	}
+	f.Decls = decls
+	return removed
+}
+
+// blankUnusedImports turns the imports that are not referenced
+// anymore into blank imports, so the package initialization stays the same.
+func blankUnusedImports(info *types.Info, f *ast.File) {
+	uses := collectImportUses(info, f)
+	for _, spec := range f.Imports {
+		pkgName, ok := importObject(info, spec).(*types.PkgName)
+		if ok && !uses.isUsed(pkgName) && pkgName.Name() != "_" && spec.Path.Value != `"C"` {
+			spec.Name = &ast.Ident{Name: "_", NamePos: spec.Pos()}
+		}
+	}
+}
+
+// collectLinkedNames adds the names of f declarations that are referenced
+// by the go:linkname and cgo export directives to set.
+//
+// The directives are only available if f was parsed with parser.ParseComments.
+func collectLinkedNames(f *ast.File, set map[string]bool) {
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			var args []string
+			switch {
+			case strings.HasPrefix(c.Text, "//go:linkname "):
+				args = strings.Fields(c.Text[len("//go:linkname "):])
+			case strings.HasPrefix(c.Text, "//export "):
+				args = strings.Fields(c.Text[len("//export "):])
+			}
+			if len(args) != 0 {
+				set[args[0]] = true
+			}
+		}
+	}
+}
+
+// canSplitConstDecl reports whether the decl specs can be removed separately.
+// Removing a spec shifts iota of the following specs and the implicit
+// repetition of the previous expression depends on the preceding specs.
+func canSplitConstDecl(decl *ast.GenDecl) bool {
+	for _, spec := range decl.Specs {
+		spec := spec.(*ast.ValueSpec)
+		if len(spec.Values) == 0 {
+			return false
+		}
+		for _, v := range spec.Values {
+			usesIota := false
+			ast.Inspect(v, func(n ast.Node) bool {
+				if ident, ok := n.(*ast.Ident); ok && ident.Name == "iota" {
+					usesIota = true
+				}
+				return !usesIota
+			})
+			if usesIota {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// recvTypeName returns the type name of the method receiver base type.
+func recvTypeName(info *types.Info, decl *ast.FuncDecl) types.Object {
+	fn, ok := info.Defs[decl.Name].(*types.Func)
+	if !ok {
+		return nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	typ := types.Unalias(recv.Type())
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = types.Unalias(ptr.Elem())
+	}
+	if named, ok := typ.(*types.Named); ok {
+		return named.Obj()
+	}
+	return nil
+}
+
+// originObject returns the generic object obj was instantiated from.
+func originObject(obj types.Object) types.Object {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.Origin()
+	case *types.Var:
+		return obj.Origin()
+	default:
+		return obj
+	}
+}
+
+// isPureExpr reports whether n evaluation can't have side effects or panic,
+// so it can be dropped. Function literals are not evaluated, only created.
+func isPureExpr(info *types.Info, n ast.Expr) bool {
+	if tv, ok := info.Types[n]; ok && tv.Value != nil {
+		return true
+	}
+	switch n := n.(type) {
+	case *ast.Ident, *ast.BasicLit, *ast.FuncLit:
+		return true
+	case *ast.ParenExpr:
+		return isPureExpr(info, n.X)
+	case *ast.SelectorExpr:
+		// Only a package-qualified name can't cause a nil dereference.
+		x, ok := n.X.(*ast.Ident)
+		if !ok {
+			return false
+		}
+		_, ok = info.Uses[x].(*types.PkgName)
+		return ok
+	case *ast.CompositeLit:
+		for _, elt := range n.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if !isPureExpr(info, kv.Key) && !isFieldKey(info, kv.Key) || !isPureExpr(info, kv.Value) {
+					return false
+				}
+			} else if !isPureExpr(info, elt) {
+				return false
+			}
+		}
+		return true
+	case *ast.UnaryExpr:
+		return n.Op != token.ARROW && isPureExpr(info, n.X)
+	case *ast.BinaryExpr:
+		return n.Op != token.QUO && n.Op != token.REM &&
+			n.Op != token.SHL && n.Op != token.SHR &&
+			isPureExpr(info, n.X) && isPureExpr(info, n.Y)
+	case *ast.CallExpr:
+		for _, arg := range n.Args {
+			if !isPureExpr(info, arg) {
+				return false
+			}
+		}
+		if tv, ok := info.Types[n.Fun]; ok && tv.IsType() {
+			// Slice to array and array pointer conversions may panic.
+			switch tv.Type.Underlying().(type) {
+			case *types.Pointer:
+				return false
+			case *types.Array:
+				_, isSlice := info.TypeOf(n.Args[0]).Underlying().(*types.Slice)
+				return !isSlice
+			}
+			return true
+		}
+		if b, ok := info.Uses[calledIdent(n.Fun)].(*types.Builtin); ok {
+			switch b.Name() {
+			case "len", "cap", "new", "complex", "real", "imag":
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// isFieldKey reports whether n is a struct literal field name.
+func isFieldKey(info *types.Info, n ast.Expr) bool {
+	ident, ok := n.(*ast.Ident)
+	if !ok {
+		return false
+	}
+	v, ok := info.Uses[ident].(*types.Var)
+	return ok && v.IsField()
+}
+
+func calledIdent(fn ast.Expr) *ast.Ident {
+	ident, _ := unparen(fn).(*ast.Ident)
+	return ident
+}
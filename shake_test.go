@@ -0,0 +1,168 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestRemoveUnused(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`func f() {}; func g() {}; func F() { f() }`, `func f(){};func F(){f()}`},
+		{`func f() { g() }; func g() {}; func h() { f() }`, ``},
+		{`func init() { f() }; func f() {}; func _() { g() }; func g() {}`, `func init(){f()};func f(){};func _(){g()};func g(){}`},
+		{`func main() { f() }; func f() {}`, `func main(){f()};func f(){}`},
+		{`func f(); func g()`, `func f();func g()`},
+
+		{`type t int; type u int; type T t`, `type t int;type T t`},
+		{`type t struct{ u }; type u struct{}; var V = t{}`, `type t struct{u};type u struct{};var V=t{}`},
+		{`type t int; func (t) f() {}`, ``},
+		{`type t int; type a = t; func (a) M() {}; func (*a) N() {}; var V t`, `type t int;type a=t;func(a)M(){};func(*a)N(){};var V t`},
+
+		// Methods of the kept types.
+		{`type T int; func (T) M() {}; func (T) m() {}; func (T) n() {}; type I interface{ m() }`,
+			`type T int;func(T)M(){};func(T)m(){};type I interface{m()}`},
+		{`type T int; func (T) m() { f() }; func (T) n() {}; func f() {}; func F() { T(0).m() }`,
+			`type T int;func(T)m(){f()};func f(){};func F(){T(0).m()}`},
+		{`type t[E any] struct{}; func (t[E]) m() { f() }; func f() {}; func F() { t[int]{}.m() }`,
+			`type t[E any] struct{};func(t[E])m(){f()};func f(){};func F(){t[int]{}.m()}`},
+
+		{`var a, b = 1, 2; var c = a; var C = c`, `var a,b=1,2;var c=a;var C=c`},
+		{`var a = 1; var b = []int{a}; var c struct{ x int }`, ``},
+		{`var a = f(); func f() int { return 0 }`, `var a=f();func f()int{return 0}`},
+		{`var a = len([]int{}); var b = int64(1); var c = new(int); var d = func() { f() }; func f() {}`, ``},
+		{`var a = x % y; var x, y = 1, 2`, `var a=x%y;var x,y=1,2`},
+		{`var _ = f; func f() {}`, `var _=f;func f(){}`},
+		{`var s []int; var a = [2]int(s); var p = (*[2]int)(s); var V = s`, `var s []int;var a=[2]int(s);var p=(*[2]int)(s);var V=s`},
+		{`var b = [2]int([2]int{})`, ``},
+
+		{`const a = 1; const b = 2; const C = a`, `const a=1;const C=a`},
+		{`const (a = iota; b; c); const C = c`, `const(a=iota;b;c);const C=c`},
+		{`const (a = iota; b; c)`, ``},
+		{`const (a, b = 1, 2; c = 3); const C = c`, `const(c=3);const C=c`},
+	}
+
+	cfg := &Config{RemoveUnused: true}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package main;" + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package main;" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestRemoveUnusedImports(t *testing.T) {
+	src := `package p
+import ("fmt"; "strings"; str "strconv")
+func f() { fmt.Println(strings.ToUpper(str.Itoa(1))) }
+func F() { fmt.Println() }
+`
+	want := `package p;import("fmt";_"strings";_"strconv");func F(){fmt.Println()}`
+	have, err := (&Config{RemoveUnused: true}).Source([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(have) != want {
+		t.Errorf("minify:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestRemoveUnusedDirectives(t *testing.T) {
+	src := `package p
+import _ "unsafe"
+//go:linkname f runtime.f
+func f() {}
+//export g
+func g() {}
+func h() {}
+`
+	want := `package p;import _"unsafe";func f(){};func g(){}`
+	have, err := (&Config{RemoveUnused: true}).Source([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(have) != want {
+		t.Errorf("minify:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestRemoveUnusedTestFiles(t *testing.T) {
+	sources := map[string]string{
+		"p.go":      `package p; func f() {}; func g() {}; func h() {}`,
+		"p_test.go": `package p; func helper() { f() }`,
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range []string{"p.go", "p_test.go"} {
+		f, err := parser.ParseFile(fset, name, sources[name], parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	files, err := (&Config{RemoveUnused: true}).Transform(fset, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have []string
+	for _, f := range files {
+		var buf strings.Builder
+		if err := Node(&buf, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		have = append(have, buf.String())
+	}
+	want := []string{`package p;func f(){}`, `package p;func helper(){f()}`}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("transform:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestRemoveUnusedExternalTests(t *testing.T) {
+	sources := []struct {
+		name string
+		src  string
+	}{
+		{"p.go", `package p; func F() { f() }; func f() {}; func g() {}`},
+		{"p_test.go", `package p; func helper() {}`},
+		{"x_test.go", `package p_test; import "example.com/p"; func unused() { p.F() }`},
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, source := range sources {
+		f, err := parser.ParseFile(fset, source.name, source.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	files, err := (&Config{RemoveUnused: true}).Transform(fset, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have []string
+	for _, f := range files {
+		var buf strings.Builder
+		if err := Node(&buf, fset, f); err != nil {
+			t.Fatal(err)
+		}
+		have = append(have, buf.String())
+	}
+	want := []string{
+		`package p;func F(){f()};func f(){}`,
+		`package p;func helper(){}`,
+		`package p_test;import"example.com/p";func unused(){p.F()}`,
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("transform:\nhave: %q\nwant: %q", have, want)
+	}
+}
@@ -0,0 +1,185 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// shortener rewrites the function body statements into their shorter equivalents:
+//
+//	var x = v  => x := v
+//	x = x + y  => x += y
+//	x += 1     => x++
+type shortener struct {
+	info *types.Info
+}
+
+func (s *shortener) shortenFile(f *ast.File) {
+	rewrite(f, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			n.List = s.shortenStmtList(n.List)
+		case *ast.CaseClause:
+			n.Body = s.shortenStmtList(n.Body)
+		case *ast.CommClause:
+			n.Body = s.shortenStmtList(n.Body)
+		case *ast.AssignStmt:
+			return s.shortenAssign(n)
+		}
+		return n
+	})
+}
+
+func (s *shortener) shortenStmtList(list []ast.Stmt) []ast.Stmt {
+	var result []ast.Stmt
+	for _, stmt := range list {
+		decl, ok := stmt.(*ast.DeclStmt)
+		if !ok {
+			result = append(result, stmt)
+			continue
+		}
+		if assigns := s.shortenVarDecl(decl.Decl.(*ast.GenDecl)); assigns != nil {
+			result = append(result, assigns...)
+		} else {
+			result = append(result, stmt)
+		}
+	}
+	return result
+}
+
+// shortenVarDecl returns the short variable declarations that are equivalent to decl.
+//
+// All names declared by var are new in their scope, so := can't
+// turn any of them into an assignment to an already declared variable.
+func (s *shortener) shortenVarDecl(decl *ast.GenDecl) []ast.Stmt {
+	if decl.Tok != token.VAR {
+		return nil
+	}
+	var list []ast.Stmt
+	for _, spec := range decl.Specs {
+		spec := spec.(*ast.ValueSpec)
+		if spec.Type != nil || len(spec.Values) == 0 {
+			return nil
+		}
+		hasNewVar := false
+		lhs := make([]ast.Expr, len(spec.Names))
+		for i, ident := range spec.Names {
+			hasNewVar = hasNewVar || ident.Name != "_"
+			lhs[i] = ident
+		}
+		if !hasNewVar {
+			return nil
+		}
+		list = append(list, &ast.AssignStmt{
+			Lhs:    lhs,
+			TokPos: spec.Pos(),
+			Tok:    token.DEFINE,
+			Rhs:    spec.Values,
+		})
+	}
+	return list
+}
+
+func (s *shortener) shortenAssign(n *ast.AssignStmt) ast.Stmt {
+	if len(n.Lhs) != 1 || len(n.Rhs) != 1 {
+		return n
+	}
+	lhs := n.Lhs[0]
+	if isGlobalIdent(s.info, lhs, "_") || !isSideEffectFree(s.info, lhs) {
+		return n
+	}
+
+	if n.Tok == token.ASSIGN {
+		bin, ok := n.Rhs[0].(*ast.BinaryExpr)
+		if !ok || assignOp[bin.Op] == token.ILLEGAL {
+			return n
+		}
+		// The operation result must have the lhs type, so the implicit
+		// conversion of the assignment is not lost.
+		if !types.Identical(s.info.TypeOf(lhs), s.info.TypeOf(bin)) {
+			return n
+		}
+		var y ast.Expr
+		switch {
+		case sameExpr(lhs, bin.X):
+			y = bin.Y
+		case sameExpr(lhs, bin.Y) && s.isCommutative(bin) && isSideEffectFree(s.info, bin.X):
+			y = bin.X
+		default:
+			return n
+		}
+		n = &ast.AssignStmt{Lhs: n.Lhs, TokPos: n.TokPos, Tok: assignOp[bin.Op], Rhs: []ast.Expr{y}}
+	}
+
+	// x++ is defined as x += 1 with the untyped constant 1.
+	var incDec token.Token
+	switch n.Tok {
+	case token.ADD_ASSIGN:
+		incDec = token.INC
+	case token.SUB_ASSIGN:
+		incDec = token.DEC
+	default:
+		return n
+	}
+	if lit, ok := n.Rhs[0].(*ast.BasicLit); ok && lit.Kind == token.INT && lit.Value == "1" {
+		return &ast.IncDecStmt{X: lhs, TokPos: n.TokPos, Tok: incDec}
+	}
+	return n
+}
+
+// assignOp maps the binary operators to their assignment forms.
+var assignOp = map[token.Token]token.Token{
+	token.ADD:     token.ADD_ASSIGN,
+	token.SUB:     token.SUB_ASSIGN,
+	token.MUL:     token.MUL_ASSIGN,
+	token.QUO:     token.QUO_ASSIGN,
+	token.REM:     token.REM_ASSIGN,
+	token.AND:     token.AND_ASSIGN,
+	token.OR:      token.OR_ASSIGN,
+	token.XOR:     token.XOR_ASSIGN,
+	token.SHL:     token.SHL_ASSIGN,
+	token.SHR:     token.SHR_ASSIGN,
+	token.AND_NOT: token.AND_NOT_ASSIGN,
+}
+
+// isCommutative reports whether the operands of n can be swapped.
+// String concatenation is not commutative.
+func (s *shortener) isCommutative(n *ast.BinaryExpr) bool {
+	switch n.Op {
+	case token.ADD, token.MUL, token.AND, token.OR, token.XOR:
+		typ, ok := s.info.TypeOf(n).Underlying().(*types.Basic)
+		return ok && typ.Info()&types.IsNumeric != 0
+	default:
+		return false
+	}
+}
+
+// isSideEffectFree reports whether n evaluation has no side effects,
+// so it can be evaluated once instead of twice or not evaluated at all.
+// The possible run-time panics are not considered.
+func isSideEffectFree(info *types.Info, n ast.Expr) bool {
+	free := true
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.CallExpr:
+			// Conversions are the only calls that are allowed.
+			if tv, ok := info.Types[n.Fun]; !ok || !tv.IsType() {
+				free = false
+			}
+		case *ast.UnaryExpr:
+			if n.Op == token.ARROW {
+				free = false
+			}
+		case *ast.FuncLit:
+			free = false
+		}
+		return free
+	})
+	return free
+}
+
+// sameExpr reports whether x and y are the same expression syntactically.
+func sameExpr(x, y ast.Expr) bool {
+	return types.ExprString(x) == types.ExprString(y)
+}
@@ -0,0 +1,67 @@
+package minformat
+
+import (
+	"testing"
+)
+
+func TestShortenStmts(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`var v = 10; _ = v`, `v:=10;_=v`},
+		{`var v, w = 1, "a"; _, _ = v, w`, `v,w:=1,"a";_,_=v,w`},
+		{`var v, _ = f2(); _ = v`, `v,_:=f2();_=v`},
+		{`var (v = 1; w = 2); _, _ = v, w`, `v:=1;w:=2;_,_=v,w`},
+		{`{ var x = x; _ = x }`, `{x:=x;_=x}`},
+		{`x = x + 2`, `x+=2`},
+		{`x = x * (y + 1)`, `x*=(y+1)`},
+		{`x = x << 2`, `x<<=2`},
+		{`x = x &^ y`, `x&^=y`},
+		{`s = s + "a"`, `s+="a"`},
+		{`x = y * x`, `x*=y`},
+		{`xs[0] = xs[0] - y`, `xs[0]-=y`},
+		{`p.x = p.x | 4`, `p.x|=4`},
+		{`x += 1`, `x++`},
+		{`x -= 1`, `x--`},
+		{`x = x + 1`, `x++`},
+		{`f = f - 1`, `f--`},
+		{`m["k"] = m["k"] + 1`, `m["k"]++`},
+		{`for i := 0; i < 10; i = i + 1 {}`, `for i:=0;i<10;i++{}`},
+
+		// A var without initializer or with an explicit type.
+		{`var z int; _ = z`, `var z int;_=z`},
+		{`var z int = 1; _ = z`, `var z int=1;_=z`},
+		{`var (z = 1; w int); _, _ = z, w`, `var(z=1;w int);_,_=z,w`},
+		// No new variables.
+		{`var _ = 1`, `var _=1`},
+		// The string concatenation is not commutative.
+		{`s = "a" + s`, `s="a"+s`},
+		// The lhs would be evaluated once instead of twice.
+		{`xs[g()] = xs[g()] + 1`, `xs[g()]=xs[g()]+1`},
+		// The rhs would be evaluated after the lhs.
+		{`x = g() + x`, `x=g()+x`},
+		{`x = x - y - 1`, `x=x-y-1`},
+		{`x = y - x`, `x=y-x`},
+		{`x = x + 2 * y`, `x+=2*y`},
+		// The operation result type differs from the lhs type.
+		{`f = float64(x) + 1`, `f=float64(x)+1`},
+		{`x += 2`, `x+=2`},
+		{`f += 1.0`, `f+=1.0`},
+	}
+
+	cfg := &Config{ShortenStmts: true}
+	for _, test := range tests {
+		const header = "package p;type T struct{x int};func f2() (int, int);func g() int;" +
+			"func _(x, y int, f float64, s string, xs []int, p *T, m map[string]int) {"
+		have, err := cfg.Source([]byte(header + test.src + "}"))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;type T struct{x int};func f2()(int,int);func g()int;" +
+			"func _(x,y int,f float64,s string,xs []int,p *T,m map[string]int){" + test.want + "}"
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
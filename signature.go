@@ -0,0 +1,120 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// compactSignatures shortens the function signatures in f:
+// the parameter and result names that are never referenced are dropped
+// and the consecutive parameters of the same type are merged.
+func compactSignatures(info *types.Info, f *ast.File) {
+	withBody := make(map[*ast.FuncType]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			withBody[n.Type] = true
+			compactSignature(info, n.Recv, n.Type, n.Body)
+		case *ast.FuncLit:
+			withBody[n.Type] = true
+			compactSignature(info, nil, n.Type, n.Body)
+		case *ast.FuncType:
+			// Interface methods and function types have no body.
+			if !withBody[n] {
+				compactSignature(info, nil, n, nil)
+			}
+		}
+		return true
+	})
+}
+
+func compactSignature(info *types.Info, recv *ast.FieldList, typ *ast.FuncType, body *ast.BlockStmt) {
+	used := make(map[types.Object]bool)
+	bareReturn := false
+	if body != nil {
+		ast.Inspect(body, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok {
+				if obj := info.Uses[ident]; obj != nil {
+					used[obj] = true
+				}
+			}
+			return true
+		})
+		ast.Inspect(body, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ReturnStmt:
+				bareReturn = bareReturn || len(n.Results) == 0
+			case *ast.FuncLit:
+				return false
+			}
+			return true
+		})
+	}
+
+	// Either all parameters have names or none of them do.
+	if isUnused(info, recv, used) {
+		dropNames(recv)
+	}
+	if isUnused(info, typ.Params, used) {
+		dropNames(typ.Params)
+	}
+	// The bare return refers to the named results implicitly.
+	if !bareReturn && isUnused(info, typ.Results, used) {
+		dropNames(typ.Results)
+	}
+
+	mergeFields(typ.TypeParams)
+	mergeFields(typ.Params)
+	mergeFields(typ.Results)
+}
+
+// isUnused reports whether none of the list names are referenced.
+// The names info knows nothing about are considered used.
+func isUnused(info *types.Info, list *ast.FieldList, used map[types.Object]bool) bool {
+	if list == nil {
+		return false
+	}
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			if name.Name == "_" {
+				continue
+			}
+			if obj := info.Defs[name]; obj == nil || used[obj] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func dropNames(list *ast.FieldList) {
+	var fields []*ast.Field
+	for _, field := range list.List {
+		if len(field.Names) == 0 {
+			fields = append(fields, field)
+			continue
+		}
+		// `a, b int` becomes `int, int`.
+		for range field.Names {
+			fields = append(fields, &ast.Field{Type: field.Type})
+		}
+	}
+	list.List = fields
+}
+
+// mergeFields turns `a int, b int` into `a, b int`.
+func mergeFields(list *ast.FieldList) {
+	if list == nil || len(list.List) == 0 {
+		return
+	}
+	fields := list.List[:1]
+	for _, field := range list.List[1:] {
+		prev := fields[len(fields)-1]
+		if len(prev.Names) != 0 && len(field.Names) != 0 && sameExpr(prev.Type, field.Type) {
+			prev.Names = append(prev.Names, field.Names...)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	list.List = fields
+}
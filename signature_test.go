@@ -0,0 +1,90 @@
+package minformat
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestCompactSignatures(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`func f(a int, b int) int { return a + b }`, `func f(a,b int)int{return a+b}`},
+		{`func f(a int, b int, c string, d string) { g(a, b, c, d) }`, `func f(a,b int,c,d string){g(a,b,c,d)}`},
+		{`func f(a, b int, c int) { g(a, b, c) }`, `func f(a,b,c int){g(a,b,c)}`},
+		{`func f(a int, b string, c int) { g(a, b, c) }`, `func f(a int,b string,c int){g(a,b,c)}`},
+		{`func f(a int, b ...int) { g(a, b) }`, `func f(a int,b ...int){g(a,b)}`},
+		{`func f[A any, B any](a A, b B) { g(a, b) }`, `func f[A,B any](a A,b B){g(a,b)}`},
+
+		// Unused parameters.
+		{`func f(a int, b string) {}`, `func f(int,string){}`},
+		{`func f(a, b int) {}`, `func f(int,int){}`},
+		{`func f(a, _ int) { g(a) }`, `func f(a,_ int){g(a)}`},
+		{`func f(a int, b int) { func() { g(b) }() }`, `func f(a,b int){func(){g(b)}()}`},
+		{`func (t *T) m(a int) {}`, `func(*T)m(int){}`},
+		{`func (t *T) m(a int) { g(t) }`, `func(t *T)m(int){g(t)}`},
+		{`var f = func(a int) {}`, `var f=func(int){}`},
+		{`func f(a int) int`, `func f(int)int`},
+
+		// Unused results.
+		{`func f() (n int) { return 1 }`, `func f()int{return 1}`},
+		{`func f() (n int, err error) { return 1, nil }`, `func f()(int,error){return 1,nil}`},
+		{`func f() (n int) { n = 1; return n }`, `func f()(n int){n=1;return n}`},
+		{`func f() (n int) { return }`, `func f()(n int){return }`},
+		{`func f() (n int) { g(func() { return }); return 1 }`, `func f()int{g(func(){return });return 1}`},
+		{`func f() (a int, b int) { defer func() { a = 2 }(); return 1, 2 }`, `func f()(a,b int){defer func(){a=2}();return 1,2}`},
+
+		// No bodies.
+		{`type I interface { m(a int, b int) (n int, err error) }`, `type I interface{m(int,int)(int,error)}`},
+		{`type F func(a int, b string) (ok bool)`, `type F func(int,string)bool`},
+	}
+
+	cfg := &Config{CompactSignatures: true}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package p;" + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestCompactSignaturesNoObjects(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`func f(a int, b int) int { return a + b }`, `func f(a,b int)int{return a+b}`},
+		{`func (t *T) m(a int) { g(t) }`, `func(t *T)m(int){g(t)}`},
+		{`func f() (n int) { n = 1; return n }`, `func f()(n int){n=1;return n}`},
+		{`func f(a int) { { a := 1; g(a) } }`, `func f(int){{a:=1;g(a)}}`},
+	}
+
+	cfg := &Config{CompactSignatures: true}
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", "package p;type T int;func g(...any);"+test.src, parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := cfg.Transform(fset, []*ast.File{f})
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		var buf bytes.Buffer
+		if err := cfg.Node(&buf, fset, files[0]); err != nil {
+			t.Fatal(err)
+		}
+		want := "package p;type T int;func g(...any);" + test.want
+		if have := buf.String(); have != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// removeNonAPI removes the declarations that are not a part of the package API:
+// the unexported declarations the exported ones don't reference outside
+// the function bodies, init functions and the imports only the function bodies use.
+//
+// The methods of the kept types are kept if they're exported
+// or they may be needed to implement an interface of the package.
+func removeNonAPI(tc *typeChecker, files []*ast.File) error {
+	if err := tc.check(files); err != nil {
+		return err
+	}
+	s := newTreeShaker(tc.info, files)
+	s.skipBodies = true
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil && ast.IsExported(decl.Name.Name) {
+					s.mark(s.nodes[decl])
+				}
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if !isExportedSpec(spec) {
+						continue
+					}
+					if u := s.nodes[spec]; u != nil {
+						s.mark(u)
+					} else {
+						s.mark(s.nodes[decl])
+					}
+				}
+			}
+		}
+	}
+	s.propagate()
+
+	for _, f := range files {
+		s.removeDecls(f)
+
+		var nodes []ast.Node
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok {
+				nodes = append(nodes, withoutBody(fn))
+			} else {
+				nodes = append(nodes, decl)
+			}
+		}
+		keep := make(map[*ast.ImportSpec]bool)
+		for _, spec := range usedImports(tc.info, f, nodes) {
+			keep[spec] = true
+		}
+		removeImports(f, keep)
+	}
+	return nil
+}
+
+// removeImports removes the imports of f that are not in keep, except for the "C" import.
+func removeImports(f *ast.File, keep map[*ast.ImportSpec]bool) {
+	decls := f.Decls[:0]
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			decls = append(decls, decl)
+			continue
+		}
+		specs := gd.Specs[:0]
+		for _, spec := range gd.Specs {
+			spec := spec.(*ast.ImportSpec)
+			if keep[spec] || spec.Path.Value == `"C"` {
+				specs = append(specs, spec)
+			}
+		}
+		gd.Specs = specs
+		if len(specs) != 0 {
+			decls = append(decls, gd)
+		}
+	}
+	f.Decls = decls
+
+	imports := f.Imports[:0]
+	for _, spec := range f.Imports {
+		if keep[spec] || spec.Path.Value == `"C"` {
+			imports = append(imports, spec)
+		}
+	}
+	f.Imports = imports
+}
+
+// isExportedSpec reports whether spec declares an exported name.
+func isExportedSpec(spec ast.Spec) bool {
+	switch spec := spec.(type) {
+	case *ast.TypeSpec:
+		return ast.IsExported(spec.Name.Name)
+	case *ast.ValueSpec:
+		for _, name := range spec.Names {
+			if ast.IsExported(name.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withoutBody returns a copy of fn without the body.
+func withoutBody(fn *ast.FuncDecl) *ast.FuncDecl {
+	c := *fn
+	c.Body = nil
+	return &c
+}
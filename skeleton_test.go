@@ -0,0 +1,76 @@
+package minformat
+
+import (
+	"testing"
+)
+
+func TestSkeleton(t *testing.T) {
+	tests := []struct {
+		src   string
+		want  string
+		stubs string
+	}{
+		{
+			`func F(x int) int { return g(x) }; func g(x int) int { return x }`,
+			`func F(x int)int`,
+			`func F(x int)int{panic(0)}`,
+		},
+		{
+			`func F() { g() }; func init() { g() }; func g() {}; func h()`,
+			`func F()`,
+			`func F(){}`,
+		},
+		{
+			`type T struct{ x t }; type t int; type u int; func (T) M() {}; func (T) m() {}; func (t) M(u) {}`,
+			`type T struct{x t};type t int;type u int;func(T)M();func(t)M(u)`,
+			`type T struct{x t};type t int;type u int;func(T)M(){};func(t)M(u){}`,
+		},
+		{
+			`type I interface{ m() }; type T int; func (T) m() {}; func (T) n() {}`,
+			`type I interface{m()};type T int;func(T)m()`,
+			`type I interface{m()};type T int;func(T)m(){}`,
+		},
+		{
+			`const C = c; const c = 1; var V = f(); func f() int { return 0 }; var v = 1`,
+			`const C=c;const c=1;var V=f();func f()int`,
+			`const C=c;const c=1;var V=f();func f()int{panic(0)}`,
+		},
+		{
+			`var F = func() { g() }; func g() {}`,
+			`var F=func(){g()};func g()`,
+			`var F=func(){g()};func g(){}`,
+		},
+	}
+
+	for _, test := range tests {
+		for _, stubs := range []bool{false, true} {
+			cfg := &Config{Skeleton: true, SkeletonStubs: stubs}
+			have, err := cfg.Source([]byte("package p;" + test.src))
+			if err != nil {
+				t.Fatalf("minify %s: %v", test.src, err)
+			}
+			want := "package p;" + test.want
+			if stubs {
+				want = "package p;" + test.stubs
+			}
+			if string(have) != want {
+				t.Errorf("minify %s (stubs=%v):\nhave: %q\nwant: %q", test.src, stubs, have, want)
+			}
+		}
+	}
+}
+
+func TestSkeletonImports(t *testing.T) {
+	src := `package p
+import ("fmt"; "io"; "strings"; _ "embed")
+func F(w io.Writer) { fmt.Fprint(w, strings.ToUpper("")) }
+`
+	want := `package p;import("io");func F(w io.Writer){}`
+	have, err := (&Config{Skeleton: true, SkeletonStubs: true}).Source([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(have) != want {
+		t.Errorf("minify:\nhave: %q\nwant: %q", have, want)
+	}
+}
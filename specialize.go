@@ -0,0 +1,461 @@
+package minformat
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"go/token"
+	"go/types"
+	"io"
+	"path/filepath"
+	"strconv"
+)
+
+// specializer resolves runtime.GOOS and runtime.GOARCH comparisons
+// for a fixed build context and removes the branches that become dead.
+type specializer struct {
+	ctxt *build.Context
+	info *types.Info
+	file *ast.File
+
+	// runtimePkg is a name under which runtime is imported.
+	// It's "." for the dot import and empty if runtime is not imported.
+	runtimePkg string
+
+	// removedPkgs are the imports referenced from the removed code.
+	removedPkgs map[*types.PkgName]bool
+}
+
+// specialize drops files that are not a part of the package under ctxt
+// and removes the code that is unreachable on the ctxt GOOS/GOARCH.
+//
+// The imports are resolved with imp to tell which of them the removed code used;
+// if imp is nil, only the package names are looked up in ctxt.
+func specialize(tc *typeChecker, ctxt *build.Context, imp types.Importer, files []*ast.File) []*ast.File {
+	result := files[:0]
+	for _, f := range files {
+		if matchFile(ctxt, tc.fset, f) {
+			result = append(result, f)
+		}
+	}
+	if len(result) == 0 {
+		return result
+	}
+	if imp == nil {
+		dir := filepath.Dir(tc.fset.File(result[0].Pos()).Name())
+		imp = packageNamer{ctxt: ctxt, dir: dir}
+	}
+	info := tc.resolveWith(imp, result)
+	for _, f := range result {
+		s := specializer{ctxt: ctxt, info: info, file: f, removedPkgs: make(map[*types.PkgName]bool)}
+		s.specializeFile()
+	}
+	return result
+}
+
+// packageNamer imports the packages found in the build context
+// without their contents: only the package names are known,
+// which is enough to tell the references to the imports.
+type packageNamer struct {
+	ctxt *build.Context
+	dir  string
+}
+
+func (p packageNamer) Import(path string) (*types.Package, error) {
+	if path == "C" {
+		return unresolvedImporter{}.Import(path)
+	}
+	bp, err := p.ctxt.Import(path, p.dir, 0)
+	if err != nil {
+		return nil, err
+	}
+	pkg := types.NewPackage(path, bp.Name)
+	pkg.MarkComplete()
+	return pkg, nil
+}
+
+// matchFile reports whether f should be included into the package
+// according to its name and build constraints.
+//
+// Build constraints are only available if f was parsed with parser.ParseComments.
+func matchFile(ctxt *build.Context, fset *token.FileSet, f *ast.File) bool {
+	filename := fset.File(f.Pos()).Name()
+
+	// build.Context.MatchFile reads the file header to evaluate the constraints.
+	// We don't have the original source, so we build an equivalent header
+	// from the AST instead: the constraints and the "C" import are all it needs.
+	var header bytes.Buffer
+	for _, cg := range f.Comments {
+		if cg.Pos() >= f.Package {
+			break
+		}
+		for _, c := range cg.List {
+			if constraint.IsGoBuild(c.Text) || constraint.IsPlusBuild(c.Text) {
+				header.WriteString(c.Text)
+				header.WriteByte('\n')
+			}
+		}
+	}
+	header.WriteString("\npackage p\n")
+	for _, spec := range f.Imports {
+		if spec.Path.Value == `"C"` {
+			header.WriteString("import \"C\"\n")
+			break
+		}
+	}
+
+	c := *ctxt
+	c.OpenFile = func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(header.Bytes())), nil
+	}
+	ok, err := c.MatchFile(filepath.Dir(filename), filepath.Base(filename))
+	return err == nil && ok
+}
+
+func (s *specializer) specializeFile() {
+	for _, spec := range s.file.Imports {
+		if pkgName, ok := importObject(s.info, spec).(*types.PkgName); ok && pkgName.Imported().Path() == "runtime" {
+			s.runtimePkg = pkgName.Name()
+		}
+	}
+	if s.runtimePkg == "" || s.runtimePkg == "_" {
+		return
+	}
+
+	rewrite(s.file, func(n ast.Node) ast.Node {
+		switch n := n.(type) {
+		case ast.Expr:
+			return s.foldExpr(n)
+		case *ast.BlockStmt:
+			n.List = s.specializeStmtList(n.List)
+		case *ast.CaseClause:
+			n.Body = s.specializeStmtList(n.Body)
+		case *ast.CommClause:
+			n.Body = s.specializeStmtList(n.Body)
+		case *ast.IfStmt:
+			// The else branch is not a part of any statement list.
+			els, ok := n.Else.(*ast.IfStmt)
+			if !ok {
+				break
+			}
+			res := s.specializeIf(els)
+			switch {
+			case len(res) == 0:
+				n.Else = nil
+			case len(res) == 1 && isBlockOrIf(res[0]):
+				n.Else = res[0]
+			default:
+				n.Else = &ast.BlockStmt{List: res}
+			}
+		}
+		return n
+	})
+
+	discardUnusedImports(s.info, s.file, s.removedPkgs)
+}
+
+func isBlockOrIf(n ast.Stmt) bool {
+	switch n.(type) {
+	case *ast.BlockStmt, *ast.IfStmt:
+		return true
+	default:
+		return false
+	}
+}
+
+// runtimeConst returns the value of n if it's runtime.GOOS or runtime.GOARCH.
+func (s *specializer) runtimeConst(n ast.Expr) (string, bool) {
+	var name string
+	switch n := unparen(n).(type) {
+	case *ast.SelectorExpr:
+		if !isGlobalIdent(s.info, n.X, s.runtimePkg) {
+			return "", false
+		}
+		name = n.Sel.Name
+	case *ast.Ident:
+		if s.runtimePkg != "." {
+			return "", false
+		}
+		// The dot-imported names are only resolved if the importer
+		// knows the package contents; otherwise a resolved name
+		// is declared in the package.
+		if obj := s.info.ObjectOf(n); obj != nil && (obj.Pkg() == nil || obj.Pkg().Path() != "runtime") {
+			return "", false
+		}
+		name = n.Name
+	default:
+		return "", false
+	}
+	switch name {
+	case "GOOS":
+		return s.ctxt.GOOS, true
+	case "GOARCH":
+		return s.ctxt.GOARCH, true
+	default:
+		return "", false
+	}
+}
+
+// stringLit returns the value of n if it's a string literal.
+func stringLit(n ast.Expr) (string, bool) {
+	lit, ok := unparen(n).(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	v, err := strconv.Unquote(lit.Value)
+	return v, err == nil
+}
+
+func (s *specializer) foldExpr(n ast.Expr) ast.Expr {
+	switch n := n.(type) {
+	case *ast.ParenExpr:
+		if v, ok := boolConst(s.info, n.X); ok {
+			return newBoolConst(v, n.Pos())
+		}
+
+	case *ast.UnaryExpr:
+		if v, ok := boolConst(s.info, n.X); ok && n.Op == token.NOT {
+			return newBoolConst(!v, n.Pos())
+		}
+
+	case *ast.BinaryExpr:
+		switch n.Op {
+		case token.EQL, token.NEQ:
+			x, y := n.X, n.Y
+			if _, ok := s.runtimeConst(y); ok {
+				x, y = y, x
+			}
+			actual, ok1 := s.runtimeConst(x)
+			want, ok2 := stringLit(y)
+			if ok1 && ok2 {
+				s.removeExpr(n)
+				return newBoolConst((actual == want) == (n.Op == token.EQL), n.Pos())
+			}
+
+		case token.LAND, token.LOR:
+			// For && the neutral element is true and the absorbing one is false;
+			// for || it's the other way around.
+			neutral := n.Op == token.LAND
+			// Dropping an operand that references a local variable
+			// could leave that variable unused.
+			if v, ok := boolConst(s.info, n.X); ok {
+				if v == neutral {
+					return n.Y
+				}
+				if len(localVars(s.info, n.Y)) == 0 {
+					s.removeExpr(n.Y)
+					return n.X
+				}
+			}
+			if v, ok := boolConst(s.info, n.Y); ok {
+				if v == neutral {
+					return n.X
+				}
+				// The left operand is evaluated anyway;
+				// it can only be dropped if that has no observable effect.
+				if isSafeExpr(s.info, n.X) && len(localVars(s.info, n.X)) == 0 {
+					s.removeExpr(n.X)
+					return n.Y
+				}
+			}
+		}
+	}
+	return n
+}
+
+func (s *specializer) specializeStmtList(list []ast.Stmt) []ast.Stmt {
+	var result []ast.Stmt
+	for _, stmt := range list {
+		switch stmt := stmt.(type) {
+		case *ast.IfStmt:
+			result = append(result, s.specializeIf(stmt)...)
+		case *ast.SwitchStmt:
+			result = append(result, s.specializeSwitch(stmt)...)
+		default:
+			result = append(result, stmt)
+		}
+	}
+	return result
+}
+
+// specializeIf returns the statements that replace n.
+func (s *specializer) specializeIf(n *ast.IfStmt) []ast.Stmt {
+	v, ok := boolConst(s.info, n.Cond)
+	if !ok {
+		return []ast.Stmt{n}
+	}
+	var live, dead ast.Stmt = n.Body, n.Else
+	if !v {
+		live, dead = dead, live
+	}
+	if dead != nil && !canRemove(dead) {
+		return []ast.Stmt{n}
+	}
+
+	var list []ast.Stmt
+	if n.Init != nil {
+		list = append(list, n.Init)
+	}
+	if dead != nil {
+		list = append(list, s.removeStmt(dead)...)
+	}
+	if live != nil {
+		list = append(list, live)
+	}
+	if n.Init == nil && len(list) <= 1 {
+		return list
+	}
+	// A block keeps the init statement declarations in their own scope.
+	return []ast.Stmt{&ast.BlockStmt{Lbrace: n.Pos(), List: list, Rbrace: n.End()}}
+}
+
+// specializeSwitch returns the statements that replace n.
+//
+// Only the `switch runtime.GOOS { case "a", "b": ... }` form is handled.
+func (s *specializer) specializeSwitch(n *ast.SwitchStmt) []ast.Stmt {
+	actual, ok := s.runtimeConst(n.Tag)
+	if !ok || n.Init != nil {
+		return []ast.Stmt{n}
+	}
+
+	var live, deflt *ast.CaseClause
+	for _, stmt := range n.Body.List {
+		cc := stmt.(*ast.CaseClause)
+		if cc.List == nil {
+			deflt = cc
+			continue
+		}
+		for _, x := range cc.List {
+			v, ok := stringLit(x)
+			if !ok {
+				return []ast.Stmt{n}
+			}
+			if v == actual && live == nil {
+				live = cc
+			}
+		}
+	}
+	if live == nil {
+		live = deflt
+	}
+
+	var list []ast.Stmt
+	for _, stmt := range n.Body.List {
+		cc := stmt.(*ast.CaseClause)
+		if cc == live {
+			continue
+		}
+		body := &ast.BlockStmt{Lbrace: cc.Pos(), List: cc.Body, Rbrace: cc.End()}
+		if !canRemove(body) {
+			return []ast.Stmt{n}
+		}
+		list = append(list, s.removeStmt(body)...)
+	}
+	if live != nil {
+		// The clause body can't be moved out of the switch
+		// if it uses break or fallthrough that belong to it.
+		for _, stmt := range live.Body {
+			if hasSwitchBranch(stmt) {
+				return []ast.Stmt{n}
+			}
+		}
+		list = append(list, &ast.BlockStmt{Lbrace: live.Pos(), List: live.Body, Rbrace: live.End()})
+	}
+	s.removeExpr(n.Tag)
+	return list
+}
+
+// canRemove reports whether dead code can be removed without breaking the program.
+//
+// Removing a labeled branch may leave its label unused, which is a compilation error.
+func canRemove(n ast.Node) bool {
+	ok := true
+	ast.Inspect(n, func(n ast.Node) bool {
+		if branch, isBranch := n.(*ast.BranchStmt); isBranch && branch.Label != nil {
+			ok = false
+		}
+		return ok
+	})
+	return ok
+}
+
+// removeExpr records the removal of the dead expression n.
+func (s *specializer) removeExpr(n ast.Expr) {
+	collectPkgRefs(s.info, n, s.removedPkgs)
+}
+
+// removeStmt records the removal of the dead statement n.
+// It returns statements that use all local variables declared
+// outside of n but referenced from it.
+//
+// Without them, the removal could make some variables unused,
+// which is a compilation error.
+func (s *specializer) removeStmt(dead ast.Stmt) []ast.Stmt {
+	collectPkgRefs(s.info, dead, s.removedPkgs)
+
+	var as ast.AssignStmt
+	for _, v := range localVars(s.info, dead) {
+		if dead.Pos() <= v.Pos() && v.Pos() < dead.End() {
+			continue
+		}
+		ident := &ast.Ident{Name: v.Name()}
+		// The statement may end up inside of the dead code removed later.
+		s.info.Uses[ident] = v
+		as.Lhs = append(as.Lhs, &ast.Ident{Name: "_"})
+		as.Rhs = append(as.Rhs, ident)
+	}
+	if len(as.Lhs) == 0 {
+		return nil
+	}
+	as.Tok = token.ASSIGN
+	return []ast.Stmt{&as}
+}
+
+// hasSwitchBranch reports whether n contains break or fallthrough
+// that refer to the enclosing switch statement.
+func hasSwitchBranch(n ast.Stmt) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BranchStmt:
+			if n.Label == nil && (n.Tok == token.BREAK || n.Tok == token.FALLTHROUGH) {
+				found = true
+			}
+		case *ast.ForStmt, *ast.RangeStmt, *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt, *ast.FuncLit:
+			// These statements capture the nested unlabeled breaks.
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// collectPkgRefs adds the imports referenced inside n to the set.
+func collectPkgRefs(info *types.Info, n ast.Node, set map[*types.PkgName]bool) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			if pkgName, ok := info.Uses[ident].(*types.PkgName); ok {
+				set[pkgName] = true
+			}
+		}
+		return true
+	})
+}
+
+// discardUnusedImports turns the imports referenced from the removed code
+// that are not referenced anymore into blank imports,
+// so the package initialization order stays the same.
+func discardUnusedImports(info *types.Info, f *ast.File, removed map[*types.PkgName]bool) {
+	if len(removed) == 0 {
+		return
+	}
+	used := make(map[*types.PkgName]bool)
+	collectPkgRefs(info, f, used)
+	for _, spec := range f.Imports {
+		pkgName, ok := importObject(info, spec).(*types.PkgName)
+		if ok && removed[pkgName] && !used[pkgName] {
+			spec.Name = &ast.Ident{Name: "_", NamePos: spec.Pos()}
+		}
+	}
+}
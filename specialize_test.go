@@ -0,0 +1,288 @@
+package minformat
+
+import (
+	"bytes"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+	"testing"
+)
+
+func TestSpecialize(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			`if runtime.GOOS == "windows" { f() } else { g() }`,
+			`{g()}`,
+		},
+		{
+			`if runtime.GOOS != "windows" { f() }`,
+			`{f()}`,
+		},
+		{
+			`if "linux" == runtime.GOOS { f() }; h()`,
+			`{f()};h()`,
+		},
+		{
+			`if runtime.GOOS == "windows" { f() }; h()`,
+			`h()`,
+		},
+		{
+			`if x := 1; runtime.GOARCH == "386" { f(x) }`,
+			`{x:=1;_=x}`,
+		},
+		{
+			`if runtime.GOOS == "windows" || runtime.GOOS == "plan9" { f() } else if runtime.GOARCH == "amd64" { g() } else { h() }`,
+			`{g()}`,
+		},
+		{
+			`if cond { f() } else if runtime.GOOS == "darwin" { g() }`,
+			`if cond{f()}`,
+		},
+		{
+			`if runtime.GOOS == "windows" && cond { f() }`,
+			``,
+		},
+		{
+			`if !(runtime.GOOS == "linux") { f() }`,
+			``,
+		},
+		{
+			`v := 1; if runtime.GOOS == "windows" { f(v) }`,
+			`v:=1;_=v`,
+		},
+		{
+			`switch runtime.GOOS { case "windows": f(); case "linux", "android": g(); default: h() }`,
+			`{g()}`,
+		},
+		{
+			`switch runtime.GOOS { case "windows": f(); default: h() }`,
+			`{h()}`,
+		},
+		{
+			`switch runtime.GOOS { case "windows": f() }`,
+			``,
+		},
+
+		// Can't be resolved statically.
+		{
+			`if runtime.GOOS == os { f() }`,
+			`if runtime.GOOS==os{f()}`,
+		},
+		{
+			`if f() && runtime.GOOS == "windows" { f() }`,
+			`if f()&&false{f()}`,
+		},
+
+		// Removal of the dead code would leave label unused.
+		{
+			`L: for { if runtime.GOOS == "windows" { break L } }`,
+			`L:for{if false{break L}}`,
+		},
+
+		// The break inside the clause belongs to the switch.
+		{
+			`switch runtime.GOOS { case "linux": if cond { break }; f() }`,
+			`switch runtime.GOOS{case "linux":if cond{break};f()}`,
+		},
+	}
+
+	cfg := &Config{BuildContext: testBuildContext("linux", "amd64")}
+	for _, test := range tests {
+		const header = "package p;import \"runtime\";var _ = runtime.Compiler;"
+		src := header + "func _() {" + test.src + "}"
+		have, err := cfg.Source([]byte(src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;import\"runtime\";var _=runtime.Compiler;func _(){" + test.want + "}"
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestSpecializeNoObjects(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			`func _() { v := 1; if runtime.GOOS == "windows" { f(v) } }`,
+			`func _(){v:=1;_=v}`,
+		},
+		{
+			`func _() { true := false; if true { f() } }`,
+			`func _(){true:=false;if true{f()}}`,
+		},
+		{
+			`func _() { runtime := struct{ GOOS string }{}; if runtime.GOOS == "windows" { f() } }`,
+			`func _(){runtime:=struct{GOOS string}{};if runtime.GOOS=="windows"{f()}}`,
+		},
+		{
+			`const false = true; func _() { if false { f() } }`,
+			`const false=true;func _(){if false{f()}}`,
+		},
+	}
+
+	cfg := &Config{BuildContext: testBuildContext("linux", "amd64")}
+	for _, test := range tests {
+		const header = "package p;import \"runtime\";var _ = runtime.Compiler;func f(...int);"
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "p.go", header+test.src, parser.SkipObjectResolution)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := cfg.Transform(fset, []*ast.File{f})
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		var buf bytes.Buffer
+		if err := cfg.Node(&buf, fset, files[0]); err != nil {
+			t.Fatal(err)
+		}
+		want := "package p;import\"runtime\";var _=runtime.Compiler;func f(...int);" + test.want
+		if have := buf.String(); have != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestSpecializeImports(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			`import ("runtime"; "os"); func _() { if runtime.GOOS == "windows" { os.Exit(1) } }`,
+			`import(_"runtime";_"os");func _(){}`,
+		},
+		{
+			`import ("runtime"; "os"); func _() { if runtime.GOOS == "windows" { os.Exit(1) }; os.Exit(0) }`,
+			`import(_"runtime";"os");func _(){os.Exit(0)}`,
+		},
+		{
+			`import rt "runtime"; const isLinux = rt.GOOS == "linux"`,
+			`import _"runtime";const isLinux=true`,
+		},
+		{
+			`import . "runtime"; const isLinux = GOOS == "linux"`,
+			`import."runtime";const isLinux=true`,
+		},
+	}
+
+	cfg := &Config{BuildContext: testBuildContext("linux", "amd64")}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package p;" + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestSpecializeImportNames(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{
+			`import ("runtime"; "gopkg.in/yaml.v2"); func _() { if runtime.GOOS == "windows" { yaml.Marshal(1) } }`,
+			`import(_"runtime";_"gopkg.in/yaml.v2");func _(){}`,
+		},
+		{
+			`import ("runtime"; "example.com/go-bar"); func _() { if runtime.GOOS == "windows" { bar.F() }; bar.G() }`,
+			`import(_"runtime";"example.com/go-bar");func _(){bar.G()}`,
+		},
+		{
+			`import ("runtime"; "example.com/go-bar"); func _() { if runtime.GOOS == "windows" { bar.F() } }`,
+			`import(_"runtime";_"example.com/go-bar");func _(){}`,
+		},
+	}
+
+	names := map[string]string{
+		"runtime":            "runtime",
+		"gopkg.in/yaml.v2":   "yaml",
+		"example.com/go-bar": "bar",
+	}
+	cfg := &Config{
+		BuildContext: testBuildContext("linux", "amd64"),
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			pkg := types.NewPackage(path, names[path])
+			pkg.MarkComplete()
+			return pkg, nil
+		}),
+	}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package p;" + test.src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;" + test.want
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestSpecializeFiles(t *testing.T) {
+	files := []struct {
+		name string
+		src  string
+	}{
+		{"a.go", "package p"},
+		{"a_windows.go", "package p"},
+		{"a_linux.go", "package p"},
+		{"a_linux_arm64.go", "package p"},
+		{"b.go", "//go:build windows || darwin\n\npackage p"},
+		{"c.go", "//go:build linux && !cgo\n\npackage p"},
+		{"d.go", "// +build linux\n\npackage p"},
+		{"e.go", "//go:build mytag\n\npackage p"},
+		{"f.go", "package p\nimport \"C\""},
+	}
+
+	ctxt := testBuildContext("linux", "amd64")
+	ctxt.CgoEnabled = true
+	ctxt.BuildTags = []string{"mytag"}
+	cfg := &Config{BuildContext: ctxt}
+
+	fset := token.NewFileSet()
+	var parsed []*ast.File
+	for _, f := range files {
+		parsedFile, err := parser.ParseFile(fset, f.name, f.src, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed = append(parsed, parsedFile)
+	}
+	result, err := cfg.Transform(fset, parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range result {
+		names = append(names, fset.File(f.Pos()).Name())
+	}
+	have := strings.Join(names, " ")
+	want := "a.go a_linux.go d.go e.go f.go"
+	if have != want {
+		t.Errorf("files mismatch:\nhave: %s\nwant: %s", have, want)
+	}
+}
+
+func testBuildContext(goos, goarch string) *build.Context {
+	ctxt := build.Default
+	ctxt.GOOS = goos
+	ctxt.GOARCH = goarch
+	ctxt.CgoEnabled = false
+	ctxt.BuildTags = nil
+	return &ctxt
+}
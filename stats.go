@@ -0,0 +1,192 @@
+package minformat
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+)
+
+// Stats describes how the minification changes the size of a file.
+type Stats struct {
+	InputBytes  int `json:"input_bytes"`
+	OutputBytes int `json:"output_bytes"`
+
+	// Savings are the bytes removed by the printer and by the transformations
+	// in the order they are applied; their sum is InputBytes-OutputBytes.
+	Savings []Saving `json:"savings"`
+
+	// The sizes of the input and output compressed with the default
+	// compression level, since that's what the transfer usually costs.
+	InputGzipBytes     int `json:"input_gzip_bytes"`
+	OutputGzipBytes    int `json:"output_gzip_bytes"`
+	InputDeflateBytes  int `json:"input_deflate_bytes"`
+	OutputDeflateBytes int `json:"output_deflate_bytes"`
+}
+
+// Saving is the size change attributed to a category.
+type Saving struct {
+	// Category is "Whitespace", "Comments" or the name of
+	// the Config field that enables a transformation, like "HoistStrings".
+	Category string `json:"category"`
+
+	// Bytes is the number of bytes removed; it's negative if the code grows.
+	Bytes int `json:"bytes"`
+}
+
+// Add adds the sizes of other to s; the savings are summed by category.
+func (s *Stats) Add(other *Stats) {
+	s.InputBytes += other.InputBytes
+	s.OutputBytes += other.OutputBytes
+	s.InputGzipBytes += other.InputGzipBytes
+	s.OutputGzipBytes += other.OutputGzipBytes
+	s.InputDeflateBytes += other.InputDeflateBytes
+	s.OutputDeflateBytes += other.OutputDeflateBytes
+	for _, saving := range other.Savings {
+		s.addSaving(saving.Category, saving.Bytes)
+	}
+}
+
+func (s *Stats) addSaving(category string, n int) {
+	for i := range s.Savings {
+		if s.Savings[i].Category == category {
+			s.Savings[i].Bytes += n
+			return
+		}
+	}
+	s.Savings = append(s.Savings, Saving{Category: category, Bytes: n})
+}
+
+// SourceStats is like Source, but it also returns the size statistics.
+//
+// The whitespace savings include everything the printer drops
+// except the comments, like the redundant semicolons.
+// Every transformation is measured by printing the file after it.
+func (cfg *Config) SourceStats(src []byte) ([]byte, *Stats, error) {
+	parserMode := parser.Mode(0)
+	if cfg.needComments() {
+		parserMode |= parser.ParseComments
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "source-input.go", src, parserMode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stats := &Stats{InputBytes: len(src)}
+	comments := commentBytes(fset, src)
+	size, err := printedSize(fset, []*ast.File{f})
+	if err != nil {
+		return nil, nil, err
+	}
+	stats.addSaving("Whitespace", len(src)-comments-size)
+	stats.addSaving("Comments", comments)
+	var printErr error
+	files, err := cfg.transform(fset, []*ast.File{f}, func(name string, files []*ast.File) {
+		newSize, err := printedSize(fset, files)
+		if err != nil {
+			if printErr == nil {
+				printErr = fmt.Errorf("print after %s: %w", name, err)
+			}
+			return
+		}
+		stats.addSaving(name, size-newSize)
+		size = newSize
+	})
+	if err == nil {
+		err = printErr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	if len(files) != 0 {
+		if err := cfg.Node(&buf, fset, files[0]); err != nil {
+			return nil, nil, err
+		}
+	}
+	if buf.Len() != size {
+		// The bodies omitted by the Skeleton printing.
+		stats.addSaving("Skeleton", size-buf.Len())
+	}
+	stats.OutputBytes = buf.Len()
+	stats.InputGzipBytes, stats.InputDeflateBytes = compressedSizes(src)
+	stats.OutputGzipBytes, stats.OutputDeflateBytes = compressedSizes(buf.Bytes())
+	return buf.Bytes(), stats, nil
+}
+
+// TokenSourceStats is like TokenSource, but it returns the output
+// and the size statistics, like SourceStats.
+//
+// There are no transformations, so all the savings
+// are either the whitespace or the comments.
+func TokenSourceStats(src []byte) ([]byte, *Stats, error) {
+	var buf bytes.Buffer
+	if err := TokenSource(&buf, bytes.NewReader(src)); err != nil {
+		return nil, nil, err
+	}
+	stats := &Stats{InputBytes: len(src), OutputBytes: buf.Len()}
+	comments := commentBytes(token.NewFileSet(), src)
+	stats.addSaving("Whitespace", len(src)-comments-buf.Len())
+	stats.addSaving("Comments", comments)
+	stats.InputGzipBytes, stats.InputDeflateBytes = compressedSizes(src)
+	stats.OutputGzipBytes, stats.OutputDeflateBytes = compressedSizes(buf.Bytes())
+	return buf.Bytes(), stats, nil
+}
+
+// commentBytes returns the total size of the comments in src.
+func commentBytes(fset *token.FileSet, src []byte) int {
+	var s scanner.Scanner
+	file := fset.AddFile("", -1, len(src))
+	s.Init(file, src, nil, scanner.ScanComments)
+	n := 0
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return n
+		}
+		if tok == token.COMMENT {
+			n += len(lit)
+		}
+	}
+}
+
+// printedSize returns the minified size of the only file in files.
+func printedSize(fset *token.FileSet, files []*ast.File) (int, error) {
+	if len(files) == 0 {
+		return 0, nil
+	}
+	var w countingWriter
+	var m minifier
+	err := m.Fprint(&w, fset, files[0])
+	return int(w), err
+}
+
+// compressedSizes returns the gzip and deflate compressed sizes of b.
+func compressedSizes(b []byte) (gzipSize, deflateSize int) {
+	var w countingWriter
+	gz := gzip.NewWriter(&w)
+	gz.Write(b)
+	gz.Close()
+	gzipSize = int(w)
+
+	w = 0
+	fl, _ := flate.NewWriter(&w, flate.DefaultCompression)
+	fl.Write(b)
+	fl.Close()
+	deflateSize = int(w)
+	return gzipSize, deflateSize
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter int
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,106 @@
+package minformat
+
+import (
+	"bytes"
+	"go/ast"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestSourceStats(t *testing.T) {
+	src := []byte(`package p
+
+// f does nothing.
+func f(a int, b int) {
+	return
+}
+
+var s1, s2 = "long string", "long string" /* twice */
+`)
+	cfg := &Config{Cleanup: true, CompactSignatures: true, HoistStrings: true}
+	out, stats, err := cfg.SourceStats(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := cfg.Source(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, want) {
+		t.Errorf("output:\nhave: %q\nwant: %q", out, want)
+	}
+
+	wantSavings := []Saving{
+		{"Whitespace", 13},
+		{"Comments", 29},
+		{"Cleanup", 7},
+		{"CompactSignatures", 4},
+		{"HoistStrings", 2},
+	}
+	if !reflect.DeepEqual(stats.Savings, wantSavings) {
+		t.Errorf("savings:\nhave: %v\nwant: %v", stats.Savings, wantSavings)
+	}
+	if stats.InputBytes != len(src) || stats.OutputBytes != len(out) {
+		t.Errorf("sizes: have %d -> %d, want %d -> %d", stats.InputBytes, stats.OutputBytes, len(src), len(out))
+	}
+	total := 0
+	for _, s := range stats.Savings {
+		total += s.Bytes
+	}
+	if total != stats.InputBytes-stats.OutputBytes {
+		t.Errorf("savings sum: have %d, want %d", total, stats.InputBytes-stats.OutputBytes)
+	}
+	if stats.InputGzipBytes == 0 || stats.OutputGzipBytes == 0 || stats.InputDeflateBytes == 0 || stats.OutputDeflateBytes == 0 {
+		t.Errorf("compressed sizes are not set: %+v", stats)
+	}
+}
+
+func TestPrintedSizeError(t *testing.T) {
+	f := &ast.File{Name: ast.NewIdent("p"), Decls: []ast.Decl{&ast.BadDecl{}}}
+	if _, err := printedSize(token.NewFileSet(), []*ast.File{f}); err == nil {
+		t.Errorf("no error for an unprintable file")
+	}
+}
+
+func TestTokenSourceStats(t *testing.T) {
+	src := []byte("package p // comment\n\nvar x = 1 /* x */\n")
+	out, stats, err := TokenSourceStats(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "package p;var x=1"; string(out) != want {
+		t.Errorf("output:\nhave: %q\nwant: %q", out, want)
+	}
+	wantSavings := []Saving{
+		{"Whitespace", 6},
+		{"Comments", 17},
+	}
+	if !reflect.DeepEqual(stats.Savings, wantSavings) {
+		t.Errorf("savings:\nhave: %v\nwant: %v", stats.Savings, wantSavings)
+	}
+	if stats.InputBytes != len(src) || stats.OutputBytes != len(out) {
+		t.Errorf("sizes: have %d -> %d, want %d -> %d", stats.InputBytes, stats.OutputBytes, len(src), len(out))
+	}
+}
+
+func TestStatsAdd(t *testing.T) {
+	s := Stats{
+		InputBytes:  10,
+		OutputBytes: 5,
+		Savings:     []Saving{{"Whitespace", 3}, {"Comments", 2}},
+	}
+	s.Add(&Stats{
+		InputBytes:  20,
+		OutputBytes: 10,
+		Savings:     []Saving{{"Whitespace", 6}, {"Cleanup", 4}},
+	})
+	want := Stats{
+		InputBytes:  30,
+		OutputBytes: 15,
+		Savings:     []Saving{{"Whitespace", 9}, {"Comments", 2}, {"Cleanup", 4}},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("add:\nhave: %+v\nwant: %+v", s, want)
+	}
+}
@@ -0,0 +1,222 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// callStripper removes the call statements of the functions and methods
+// that match the patterns, like debug logging and assertions.
+type callStripper struct {
+	info *types.Info
+	pkg  *types.Package
+
+	patterns map[string]bool
+
+	// dropSideEffects allows removing the calls with side effects in arguments.
+	dropSideEffects bool
+
+	// uses counts the references to the local variables
+	// that make them used for the compiler.
+	uses map[*types.Var]int
+
+	// params are the parameters and results, they are never unused.
+	params map[*types.Var]bool
+}
+
+// stripCalls removes the call statements that match patterns.
+//
+// A pattern is a qualified function name "path.F" or method name "path.T.M",
+// the functions and methods of the checked package can omit the path.
+//
+// If the removal makes a local variable unused, the call is replaced
+// with a blank assignment of that variable; the imports that become
+// unused are turned into blank imports.
+func stripCalls(tc *typeChecker, files []*ast.File, patterns []string, dropSideEffects bool) error {
+	if err := tc.check(files); err != nil {
+		return err
+	}
+	s := &callStripper{
+		info:            tc.info,
+		pkg:             tc.pkg,
+		patterns:        make(map[string]bool),
+		dropSideEffects: dropSideEffects,
+		uses:            make(map[*types.Var]int),
+		params:          make(map[*types.Var]bool),
+	}
+	for _, p := range patterns {
+		s.patterns[p] = true
+	}
+
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if ft, ok := n.(*ast.FuncType); ok {
+				s.addParams(ft.Params)
+				s.addParams(ft.Results)
+			}
+			return true
+		})
+		s.countUses(f, 1)
+	}
+	stripped := make(map[*ast.ExprStmt]bool)
+	labeled := make(map[ast.Stmt]bool)
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.LabeledStmt:
+				// The label needs a statement.
+				labeled[n.Stmt] = true
+			case *ast.ExprStmt:
+				if !labeled[n] && s.canStrip(n) {
+					stripped[n] = true
+					s.countUses(n, -1)
+				}
+			}
+			return true
+		})
+	}
+	if len(stripped) == 0 {
+		return nil
+	}
+
+	for _, f := range files {
+		rewrite(f, func(n ast.Node) ast.Node {
+			stmt, ok := n.(*ast.ExprStmt)
+			if !ok || !stripped[stmt] {
+				return n
+			}
+			if keep := s.keepUsed(stmt); keep != nil {
+				return keep
+			}
+			return nil
+		})
+		blankUnusedImports(tc.info, f)
+	}
+	return nil
+}
+
+// canStrip reports whether stmt is a call that matches the patterns
+// and its removal doesn't drop the side effects that are not allowed to be dropped.
+func (s *callStripper) canStrip(stmt *ast.ExprStmt) bool {
+	call, ok := unparen(stmt.X).(*ast.CallExpr)
+	if !ok || !s.matches(call) {
+		return false
+	}
+	if s.dropSideEffects {
+		return true
+	}
+	if !isSideEffectFree(s.info, call.Fun) {
+		return false
+	}
+	for _, arg := range call.Args {
+		if !isSideEffectFree(s.info, arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether the function called by call matches the patterns.
+func (s *callStripper) matches(call *ast.CallExpr) bool {
+	var ident *ast.Ident
+	switch fn := unparen(call.Fun).(type) {
+	case *ast.Ident:
+		ident = fn
+	case *ast.SelectorExpr:
+		ident = fn.Sel
+	default:
+		return false
+	}
+	fn, ok := s.info.Uses[ident].(*types.Func)
+	if !ok || fn.Pkg() == nil {
+		return false
+	}
+	fn = fn.Origin()
+
+	name := fn.Name()
+	if recv := fn.Type().(*types.Signature).Recv(); recv != nil {
+		typ := recv.Type()
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		named, ok := types.Unalias(typ).(*types.Named)
+		if !ok {
+			return false
+		}
+		name = named.Obj().Name() + "." + name
+	}
+	return s.patterns[fn.Pkg().Path()+"."+name] || fn.Pkg() == s.pkg && s.patterns[name]
+}
+
+// countUses adds delta to the use counters of the local variables referenced inside n.
+// The variables on the left side of assignments are not used by the assignments.
+func (s *callStripper) countUses(n ast.Node, delta int) {
+	assigned := make(map[*ast.Ident]bool)
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.ASSIGN || n.Tok == token.DEFINE {
+				for _, lhs := range n.Lhs {
+					if ident, ok := unparen(lhs).(*ast.Ident); ok {
+						assigned[ident] = true
+					}
+				}
+			}
+		case *ast.Ident:
+			if v, ok := s.info.Uses[n].(*types.Var); ok && s.isLocalVar(v) && !assigned[n] {
+				s.uses[v] += delta
+			}
+		}
+		return true
+	})
+}
+
+func (s *callStripper) addParams(list *ast.FieldList) {
+	if list == nil {
+		return
+	}
+	for _, field := range list.List {
+		for _, name := range field.Names {
+			if v, ok := s.info.Defs[name].(*types.Var); ok {
+				s.params[v] = true
+			}
+		}
+	}
+}
+
+// isLocalVar reports whether v is a variable declared in a function body.
+func (s *callStripper) isLocalVar(v *types.Var) bool {
+	return v.Parent() != nil && v.Parent() != s.pkg.Scope() && !v.IsField() && !s.params[v]
+}
+
+// keepUsed returns a statement that uses the local variables referenced
+// by the stripped stmt that are not used anywhere else, if there are any.
+func (s *callStripper) keepUsed(stmt *ast.ExprStmt) ast.Stmt {
+	var as ast.AssignStmt
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		ident, ok := n.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		v, ok := s.info.Uses[ident].(*types.Var)
+		if !ok || !s.isLocalVar(v) || s.uses[v] != 0 {
+			return true
+		}
+		if stmt.Pos() <= v.Pos() && v.Pos() < stmt.End() {
+			// Declared inside of stmt.
+			return true
+		}
+		// Neither this nor other stripped statements need to use v again.
+		s.uses[v]++
+		as.Lhs = append(as.Lhs, &ast.Ident{Name: "_", NamePos: ident.Pos()})
+		as.Rhs = append(as.Rhs, &ast.Ident{Name: ident.Name, NamePos: ident.Pos()})
+		return true
+	})
+	if len(as.Lhs) == 0 {
+		return nil
+	}
+	as.TokPos = stmt.Pos()
+	as.Tok = token.ASSIGN
+	return &as
+}
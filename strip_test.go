@@ -0,0 +1,91 @@
+package minformat
+
+import (
+	"testing"
+)
+
+func TestStripCalls(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`log.Printf("%d", a); f()`, `f()`},
+		{`debugf("a"); assert(a > 0); f()`, `f()`},
+		{`l.Printf("a"); l.Print("a")`, `l.Print("a")`},
+		{`if debugf("a"); a > 0 { debugf("b") }`, `if a>0{}`},
+		{`for i := 0; i < a; debugf("a") { i++ }`, `for i:=0;i<a;{i++}`},
+		{`x := a; debugf("%v", x)`, `x:=a;_=x`},
+		{`x := a; debugf("%v", x); debugf("%v", x, x)`, `x:=a;_=x`},
+		{`x := a; debugf("%v", x); f(x)`, `x:=a;f(x)`},
+		{`x := a; x = 1; debugf("%v", x)`, `x:=a;x=1;_=x`},
+		{`var s []int; debugf("%v", s[a], T(a))`, `var s []int;_=s`},
+
+		// Side effects.
+		{`debugf("%d", g())`, `debugf("%d",g())`},
+		{`debugf("%d", <-ch)`, `debugf("%d",<-ch)`},
+		{`logger().Printf("a")`, `logger().Printf("a")`},
+		// Not a call statement.
+		{`defer debugf("a"); go debugf("b")`, `defer debugf("a");go debugf("b")`},
+		{`L: debugf("a"); goto L`, `L:debugf("a");goto L`},
+		// Doesn't match.
+		{`log.Println("a"); l.Println("a")`, `log.Println("a");l.Println("a")`},
+	}
+
+	const header = `package p;import "log";type T int;var l *log.Logger;var ch chan int;` +
+		`func debugf(string, ...interface{}) {};func assert(bool) {};func g() int { return 0 };func logger() *log.Logger { return l };func f(...int) {};`
+	const wantHeader = `package p;import"log";type T int;var l *log.Logger;var ch chan int;` +
+		`func debugf(string,...interface{}){};func assert(bool){};func g()int{return 0};func logger()*log.Logger{return l};func f(...int){};`
+	cfg := &Config{StripCalls: []string{"log.Printf", "log.Logger.Printf", "debugf", "p.assert"}}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte(header + "func _(a int) {" + test.src + "}"))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := wantHeader + "func _(a int){" + test.want + "}"
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestStripCallsSideEffects(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`debugf("%d", g())`, ``},
+		{`logger().Printf("a")`, ``},
+		{`x := g(); debugf("%v", func() int { y := x; return y }())`, `x:=g();_=x`},
+	}
+
+	const header = `package p;import "log";var l *log.Logger;` +
+		`func debugf(string, ...interface{}) {};func g() int { return 0 };func logger() *log.Logger { return l };`
+	const wantHeader = `package p;import"log";var l *log.Logger;` +
+		`func debugf(string,...interface{}){};func g()int{return 0};func logger()*log.Logger{return l};`
+	cfg := &Config{StripCalls: []string{"log.Logger.Printf", "debugf"}, StripSideEffects: true}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte(header + "func _() {" + test.src + "}"))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := wantHeader + "func _(){" + test.want + "}"
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestStripCallsImports(t *testing.T) {
+	src := `package p
+import ("fmt"; "log")
+func f() { log.Printf("a"); fmt.Println() }
+`
+	want := `package p;import("fmt";_"log");func f(){fmt.Println()}`
+	have, err := (&Config{StripCalls: []string{"log.Printf"}}).Source([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(have) != want {
+		t.Errorf("minify:\nhave: %q\nwant: %q", have, want)
+	}
+}
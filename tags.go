@@ -0,0 +1,110 @@
+package minformat
+
+import (
+	"errors"
+	"go/ast"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// normalizeTags rewrites the struct tags of f into their shortest form
+// that gives the same reflect.StructTag.Lookup results.
+//
+// The tags that don't follow the reflect.StructTag convention are left as is,
+// report is called for each of them.
+func normalizeTags(f *ast.File, report func(pos token.Pos, msg string)) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		st, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+		for _, field := range st.Fields.List {
+			if field.Tag == nil {
+				continue
+			}
+			tag, err := normalizeTag(field.Tag.Value)
+			if err != nil {
+				report(field.Tag.Pos(), "struct tag "+field.Tag.Value+" is left as is: "+err.Error())
+				continue
+			}
+			if tag == "" {
+				// An empty tag is the same as no tag.
+				field.Tag = nil
+			} else {
+				field.Tag.Value = tag
+			}
+		}
+		return true
+	})
+}
+
+// normalizeTag returns the shortest literal for the struct tag lit
+// with its key:"value" pairs separated by a single space.
+// An empty result means that the tag is empty.
+func normalizeTag(lit string) (string, error) {
+	tag, err := strconv.Unquote(lit)
+	if err != nil {
+		return "", err
+	}
+
+	// The parsing follows reflect.StructTag.Lookup.
+	var pairs []string
+	for {
+		tag = strings.TrimLeft(tag, " ")
+		if tag == "" {
+			break
+		}
+		i := 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		switch {
+		case i == 0:
+			return "", errors.New("bad syntax for struct tag key")
+		case i+1 >= len(tag) || tag[i] != ':':
+			return "", errors.New("bad syntax for struct tag pair")
+		case tag[i+1] != '"':
+			return "", errors.New("bad syntax for struct tag value")
+		}
+		key := tag[:i]
+		tag = tag[i+1:]
+
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			return "", errors.New("bad syntax for struct tag value")
+		}
+		quoted := tag[:i+1]
+		tag = tag[i+1:]
+		value, err := strconv.Unquote(quoted)
+		if err != nil {
+			return "", errors.New("bad syntax for struct tag value")
+		}
+		if q := strconv.Quote(value); len(q) < len(quoted) {
+			quoted = q
+		}
+		pairs = append(pairs, key+":"+quoted)
+	}
+	if len(pairs) == 0 {
+		return "", nil
+	}
+
+	tag = strings.Join(pairs, " ")
+	quoted := strconv.Quote(tag)
+	if canBeRaw(tag) && len(tag)+2 <= len(quoted) {
+		return "`" + tag + "`", nil
+	}
+	return quoted, nil
+}
+
+// canBeRaw reports whether s can be written as a raw string literal.
+func canBeRaw(s string) bool {
+	return utf8.ValidString(s) && !strings.ContainsAny(s, "`\r\x00\ufeff")
+}
@@ -0,0 +1,77 @@
+package minformat
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"`json:\"a\"`", "`json:\"a\"`"},
+		{`"json:\"a\""`, "`json:\"a\"`"},
+		{"`  json:\"a\"   xml:\"b\"  `", "`json:\"a\" xml:\"b\"`"},
+		{"`json:\"a\"xml:\"b\"`", "`json:\"a\" xml:\"b\"`"},
+		{"`json:\"\\x61\\u00e9\"`", "`json:\"aé\"`"},
+		{"`json:\"a,omitempty\" json:\"b\"`", "`json:\"a,omitempty\" json:\"b\"`"},
+		{"`json:\"\"`", "`json:\"\"`"},
+		{`"json:\"` + "`" + `\""`, `"json:\"` + "`" + `\""`},
+
+		// Empty tags are removed.
+		{"``", ""},
+		{`"  "`, ""},
+	}
+
+	cfg := &Config{NormalizeTags: true}
+	for _, test := range tests {
+		have, err := cfg.Source([]byte("package p;type T struct { A int " + test.src + " }"))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;type T struct{A int" + test.want + "}"
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+	}
+}
+
+func TestNormalizeTagsMalformed(t *testing.T) {
+	tests := []struct {
+		src string
+		msg string
+	}{
+		{"`json`", "bad syntax for struct tag pair"},
+		{"`json:a`", "bad syntax for struct tag value"},
+		{"`json:\"a`", "bad syntax for struct tag value"},
+		{"`:\"a\"`", "bad syntax for struct tag key"},
+		{"`json:\"a\"\txml:\"b\"`", "bad syntax for struct tag key"},
+		{"`json:\"\\q\"`", "bad syntax for struct tag value"},
+	}
+
+	for _, test := range tests {
+		var reports []string
+		cfg := &Config{
+			NormalizeTags: true,
+			Report: func(pos token.Position, msg string) {
+				reports = append(reports, fmt.Sprintf("%s: %s", pos, msg))
+			},
+		}
+		src := "package p;type T struct { A int " + test.src + " }"
+		have, err := cfg.Source([]byte(src))
+		if err != nil {
+			t.Fatalf("minify %s: %v", test.src, err)
+		}
+		want := "package p;type T struct{A int" + test.src + "}"
+		if string(have) != want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, want)
+		}
+		wantReports := []string{"source-input.go:1:33: struct tag " + test.src + " is left as is: " + test.msg}
+		if !reflect.DeepEqual(reports, wantReports) {
+			t.Errorf("minify %s:\nhave reports: %q\nwant reports: %q", test.src, reports, wantReports)
+		}
+	}
+}
@@ -0,0 +1,717 @@
+package minformat
+
+import (
+	"bufio"
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"io"
+)
+
+// TokenSource reads a Go source file from r and formats it like Source does
+// with the default Config, but it works on the token stream instead of the syntax tree,
+// so it handles the huge files and the files with syntax errors.
+//
+// The input is scanned in chunks that end at line breaks and the tokens are written
+// to w as they're scanned; the comments and the whitespace are dropped and the separators
+// are decided by looking at a few tokens around. So the memory used doesn't depend on
+// the input size, only on its longest line or multi-line token: the decisions don't
+// look further than a fixed number of tokens and the longer constructs, like a huge
+// result list, keep their parentheses and semicolons.
+//
+// For a syntactically correct file the output is the same as Source output.
+// Otherwise the tokens are still written and the scanner errors are returned.
+func TokenSource(w io.Writer, r io.Reader) error {
+	return tokenSource(w, r, tokenChunkSize)
+}
+
+const (
+	// tokenChunkSize is the preferred size of the input chunks TokenSource scans.
+	tokenChunkSize = 64 << 10
+
+	// maxLookahead is the number of tokens TokenSource looks over at most
+	// to decide how to print the current one.
+	maxLookahead = 1 << 14
+)
+
+func tokenSource(w io.Writer, r io.Reader, chunkSize int) error {
+	m := tokenMinifier{out: bufio.NewWriter(w)}
+	m.s.init(r, chunkSize)
+	m.frames = append(m.frames, tokenFrame{kind: frameOther})
+	for {
+		t := m.pop()
+		if t.tok == token.EOF {
+			break
+		}
+		m.token(t)
+	}
+	if m.s.err != nil {
+		return m.s.err
+	}
+	if err := m.out.Flush(); err != nil {
+		return err
+	}
+	return m.s.errs.Err()
+}
+
+// tokenMinifier formats the tokens one by one, see TokenSource.
+type tokenMinifier struct {
+	s   chunkScanner
+	out *bufio.Writer
+
+	// queue holds the tokens scanned ahead starting at head;
+	// the decisions made in advance are recorded in them.
+	queue []scannedToken
+	head  int
+
+	// frames are the brackets that are open, the outermost
+	// one stands for the file itself.
+	frames []tokenFrame
+
+	// prev and prevText describe the last printed token.
+	prev     token.Token
+	prevText string
+
+	// space requests a space before the next printed token;
+	// spaceUnlessBrace does it unless the token is '{', as in `for{`.
+	space            bool
+	spaceUnlessBrace bool
+
+	// pkgClause is set until the semicolon ending the package clause.
+	pkgClause bool
+
+	// The state below describes the next token only.
+
+	// next is the kind of the frame the next token opens.
+	next frameKind
+	// spec is set at the start of a var, const or type spec,
+	// it's kept while the value spec names are listed.
+	spec token.Token
+	// funcName is set when the next token is a function name.
+	funcName bool
+	// chanArrow is set when the next token is the arrow of `chan<-`.
+	chanArrow bool
+}
+
+type scannedToken struct {
+	tok token.Token
+	lit string
+	// drop omits the token that the printer doesn't keep.
+	drop bool
+}
+
+func (t *scannedToken) text() string {
+	switch t.tok {
+	case token.IDENT, token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING, token.ILLEGAL:
+		return t.lit
+	case token.SEMICOLON:
+		return ";"
+	}
+	return t.tok.String()
+}
+
+type frameKind int
+
+const (
+	frameNone frameKind = iota
+	frameOther
+	frameParams
+	frameRecv
+	frameResults
+	frameTypeParams
+	frameTypeSpecParams
+	frameStruct
+	frameInterface
+	frameValueGroup
+	frameTypeGroup
+)
+
+type tokenFrame struct {
+	kind frameKind
+	// start is set at the start of a field, a method or a spec.
+	start bool
+}
+
+// peek returns the i-th token after the current one.
+func (m *tokenMinifier) peek(i int) *scannedToken {
+	for len(m.queue)-m.head <= i {
+		tok, lit := m.s.scan()
+		m.queue = append(m.queue, scannedToken{tok: tok, lit: lit})
+	}
+	return &m.queue[m.head+i]
+}
+
+func (m *tokenMinifier) pop() scannedToken {
+	t := *m.peek(0)
+	m.head++
+	switch {
+	case m.head == len(m.queue):
+		m.queue, m.head = m.queue[:0], 0
+	case m.head >= 1024 && 2*m.head >= len(m.queue):
+		n := copy(m.queue, m.queue[m.head:])
+		m.queue, m.head = m.queue[:n], 0
+	}
+	return t
+}
+
+func (m *tokenMinifier) token(t scannedToken) {
+	before := m.prev
+	if !t.drop && !m.dropped(&t) {
+		m.print(&t)
+	}
+	m.update(&t, before)
+}
+
+// dropped reports whether the printer omits t.
+func (m *tokenMinifier) dropped(t *scannedToken) bool {
+	switch t.tok {
+	case token.COMMA:
+		return isClosing(m.peek(0).tok)
+	case token.SEMICOLON:
+		switch {
+		case m.pkgClause:
+			return false
+		case m.prev == token.LBRACE || m.prev == token.SEMICOLON || m.prev == token.COLON:
+			// An empty statement.
+			return false
+		}
+		next := m.peek(0).tok
+		return next == token.RPAREN || next == token.RBRACE || next == token.EOF
+	}
+	return false
+}
+
+func (m *tokenMinifier) print(t *scannedToken) {
+	text := t.text()
+	if m.prevText != "" && (m.space || m.spaceUnlessBrace && t.tok != token.LBRACE || needSpace(m.prev, m.prevText, t.tok, text)) {
+		m.out.WriteByte(' ')
+	}
+	m.space, m.spaceUnlessBrace = false, false
+	m.out.WriteString(text)
+	m.prev, m.prevText = t.tok, text
+}
+
+// update tracks the context after t; before is the token printed before it.
+func (m *tokenMinifier) update(t *scannedToken, before token.Token) {
+	top := &m.frames[len(m.frames)-1]
+	start := top.start
+	top.start = false
+	next, spec, funcName, chanArrow := m.next, m.spec, m.funcName, m.chanArrow
+	m.next, m.spec, m.funcName, m.chanArrow = frameNone, token.ILLEGAL, false, false
+
+	switch t.tok {
+	case token.IDENT:
+		switch {
+		case funcName:
+			switch m.peek(0).tok {
+			case token.LBRACK:
+				m.next = frameTypeParams
+			case token.LPAREN:
+				m.next = frameParams
+			}
+		case spec == token.VAR:
+			switch m.peek(0).tok {
+			case token.COMMA:
+				m.spec = token.VAR
+			case token.ASSIGN, token.SEMICOLON, token.RPAREN, token.EOF:
+			default:
+				// `var x []int`
+				m.space = true
+			}
+		case spec == token.TYPE:
+			switch m.peek(0).tok {
+			case token.ASSIGN:
+			case token.LBRACK:
+				if m.typeParams() {
+					m.next = frameTypeSpecParams
+				} else {
+					m.space = true
+				}
+			default:
+				m.space = true
+			}
+		case !start:
+		case top.kind == frameParams || top.kind == frameRecv || top.kind == frameResults || top.kind == frameStruct:
+			// `x []int` in a field list.
+			m.space = m.fieldType(0)
+		case top.kind == frameTypeParams || top.kind == frameTypeSpecParams:
+			tok := m.peek(0).tok
+			m.space = tok != token.COMMA && tok != token.RBRACK
+		case top.kind == frameInterface:
+			if m.peek(0).tok == token.LPAREN {
+				m.next = frameParams
+			}
+		}
+
+	case token.COMMA:
+		if spec == token.VAR {
+			m.spec = token.VAR
+		}
+		switch top.kind {
+		case frameParams, frameRecv, frameResults, frameTypeParams, frameTypeSpecParams, frameStruct:
+			top.start = true
+		}
+
+	case token.SEMICOLON:
+		m.pkgClause = false
+		switch top.kind {
+		case frameStruct, frameInterface:
+			top.start = true
+		case frameValueGroup:
+			m.spec = token.VAR
+		case frameTypeGroup:
+			m.spec = token.TYPE
+		}
+
+	case token.LPAREN, token.LBRACK, token.LBRACE:
+		if next == frameNone {
+			next = frameOther
+		}
+		m.frames = append(m.frames, tokenFrame{kind: next, start: true})
+		switch next {
+		case frameValueGroup:
+			m.spec = token.VAR
+		case frameTypeGroup:
+			m.spec = token.TYPE
+		}
+
+	case token.RPAREN, token.RBRACK, token.RBRACE:
+		if len(m.frames) == 1 {
+			// Unbalanced brackets.
+			break
+		}
+		kind := top.kind
+		m.frames = m.frames[:len(m.frames)-1]
+		switch kind {
+		case frameParams:
+			if m.peek(0).tok == token.LPAREN {
+				m.results()
+				m.next = frameResults
+			}
+		case frameRecv:
+			m.funcName = true
+		case frameTypeParams:
+			if m.peek(0).tok == token.LPAREN {
+				m.next = frameParams
+			}
+		case frameTypeSpecParams:
+			if m.peek(0).tok != token.ASSIGN {
+				m.space = true
+			}
+		}
+
+	case token.PACKAGE:
+		m.pkgClause = true
+
+	case token.FUNC:
+		switch m.peek(0).tok {
+		case token.IDENT:
+			m.funcName = true
+		case token.LPAREN:
+			if len(m.frames) == 1 && before == token.SEMICOLON {
+				m.next = frameRecv
+			} else {
+				m.next = frameParams
+			}
+		}
+
+	case token.VAR, token.CONST:
+		if m.peek(0).tok == token.LPAREN {
+			m.next = frameValueGroup
+		} else {
+			m.spec = token.VAR
+		}
+
+	case token.TYPE:
+		switch {
+		case before == token.LPAREN:
+			// `x.(type)`
+		case m.peek(0).tok == token.LPAREN:
+			m.next = frameTypeGroup
+		default:
+			m.spec = token.TYPE
+		}
+
+	case token.STRUCT:
+		if m.peek(0).tok == token.LBRACE {
+			m.next = frameStruct
+		}
+
+	case token.INTERFACE:
+		if m.peek(0).tok == token.LBRACE {
+			m.next = frameInterface
+		}
+
+	case token.RETURN, token.CASE, token.GO, token.DEFER, token.RANGE, token.QUO:
+		m.space = true
+
+	case token.IF:
+		m.space = true
+		m.dropInitSemi()
+
+	case token.SWITCH:
+		m.spaceUnlessBrace = true
+		m.dropInitSemi()
+
+	case token.FOR:
+		m.spaceUnlessBrace = true
+		m.forHeader()
+
+	case token.CHAN:
+		if before != token.ARROW && m.peek(0).tok == token.ARROW {
+			// `chan<- T`
+			m.chanArrow = true
+		} else {
+			m.space = true
+		}
+
+	case token.ARROW:
+		if chanArrow {
+			m.space = true
+		}
+	}
+}
+
+// dropInitSemi drops the semicolon after an empty if or switch init statement.
+func (m *tokenMinifier) dropInitSemi() {
+	if t := m.peek(0); t.tok == token.SEMICOLON {
+		t.drop = true
+	}
+}
+
+// forHeader drops the semicolons of a for header that has
+// neither the init nor the post statement, like `for ;cond; {}`.
+func (m *tokenMinifier) forHeader() {
+	if m.peek(0).tok != token.SEMICOLON {
+		return
+	}
+	depth := 0
+	for i := 1; i < maxLookahead; i++ {
+		switch m.peek(i).tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+			if depth < 0 {
+				return
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				if m.peek(i+1).tok == token.LBRACE {
+					m.peek(0).drop = true
+					m.peek(i).drop = true
+				}
+				return
+			}
+		case token.EOF:
+			return
+		}
+	}
+}
+
+// results drops the parentheses around a single unnamed result
+// that starts at the next token.
+func (m *tokenMinifier) results() {
+	end, commas := m.matching(0)
+	if end < 0 || end == 1 {
+		return
+	}
+	if commas == 1 && m.peek(end-1).tok == token.COMMA {
+		// `(T,)`
+		commas = 0
+	}
+	if commas != 0 {
+		return
+	}
+	if m.peek(1).tok == token.IDENT && m.fieldType(2) {
+		return
+	}
+	m.peek(0).drop = true
+	m.peek(end).drop = true
+}
+
+// fieldType reports whether the i-th next token starts the field type
+// after the identifier at the start of a field, so the identifier
+// is the field name; it follows go/parser.
+func (m *tokenMinifier) fieldType(i int) bool {
+	switch m.peek(i).tok {
+	case token.IDENT, token.MUL, token.ARROW, token.FUNC, token.CHAN, token.MAP,
+		token.STRUCT, token.INTERFACE, token.LPAREN, token.ELLIPSIS:
+		return true
+	case token.LBRACK:
+		// `x [N]T` or `T[P]`
+		end, commas := m.matching(i)
+		if end < 0 {
+			return false
+		}
+		if end == i+1 {
+			return true
+		}
+		return commas == 0 && startsType(m.peek(end+1).tok)
+	}
+	return false
+}
+
+// typeParams reports whether the brackets after a type spec name
+// that start at the next token are type parameters rather than
+// an array length; it follows go/parser.
+func (m *tokenMinifier) typeParams() bool {
+	if m.peek(1).tok != token.IDENT {
+		return false
+	}
+	switch tok := m.peek(2).tok; tok {
+	case token.RBRACK, token.PERIOD:
+		return false
+	case token.LBRACK:
+		return true
+	case token.MUL, token.LPAREN:
+		// `P *C` and `P (C)` are type parameters if
+		// C can't be an expression or a comma follows.
+		if _, commas := m.matching(0); commas != 0 {
+			return true
+		}
+		switch m.peek(3).tok {
+		case token.LBRACK, token.STRUCT, token.FUNC, token.INTERFACE, token.MAP, token.CHAN, token.ARROW, token.TILDE:
+			return true
+		}
+		return false
+	default:
+		// Not a binary operator, so the name is alone.
+		return tok.Precedence() == token.LowestPrec
+	}
+}
+
+// matching returns the index of the bracket closing the i-th next token
+// and the number of the commas between them at the top level.
+// end is negative if the bracket isn't found within maxLookahead tokens.
+func (m *tokenMinifier) matching(i int) (end, commas int) {
+	depth := 0
+	for j := i; j < i+maxLookahead; j++ {
+		switch m.peek(j).tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+			if depth <= 0 {
+				return j, commas
+			}
+		case token.COMMA:
+			if depth == 1 {
+				commas++
+			}
+		case token.EOF:
+			return -1, commas
+		}
+	}
+	return -1, commas
+}
+
+func isClosing(tok token.Token) bool {
+	return tok == token.RPAREN || tok == token.RBRACK || tok == token.RBRACE
+}
+
+func startsType(tok token.Token) bool {
+	switch tok {
+	case token.IDENT, token.LBRACK, token.STRUCT, token.MUL, token.FUNC,
+		token.INTERFACE, token.MAP, token.CHAN, token.ARROW, token.LPAREN:
+		return true
+	}
+	return false
+}
+
+// needSpace reports whether the tokens would be scanned differently without a space.
+func needSpace(prev token.Token, prevText string, tok token.Token, text string) bool {
+	a, b := prevText[len(prevText)-1], text[0]
+	switch {
+	case isWordByte(a) && isWordByte(b):
+		return true
+	case prev == token.INT && b == '.', prev == token.PERIOD && isDigit(b):
+		return true
+	case !prev.IsOperator() || !tok.IsOperator():
+		return false
+	case prevText == "/" && (b == '/' || b == '*'):
+		// A comment.
+		return true
+	}
+	// `x - -y`, `x < -y`, `x & ^y` and the like.
+	for op := token.ADD; op <= token.TILDE; op++ {
+		s := op.String()
+		if len(s) > len(prevText) && s[:len(prevText)] == prevText && s[len(prevText)] == b {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || isDigit(c) || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// chunkScanner scans the tokens of the input read in chunks, see TokenSource.
+//
+// Every chunk but the last one ends at a line break outside of the comments
+// and the raw strings, so the tokens don't cross the chunks and scanning them
+// one by one gives the same tokens as scanning the whole input.
+type chunkScanner struct {
+	r    io.Reader
+	size int
+
+	s    scanner.Scanner
+	errs scanner.ErrorList
+	// err is the read error.
+	err error
+
+	// buf is the input read after the current chunk.
+	buf []byte
+	eof bool
+	// scanned is the part of buf state describes
+	// and cut is the end of the last chunk found there, if not zero.
+	scanned int
+	state   lexState
+	cut     int
+
+	// offset and line are the position of the current chunk in the input.
+	offset, line int
+}
+
+// lexState tells the tokens that can contain line breaks.
+type lexState int
+
+const (
+	lexCode lexState = iota
+	lexLineComment
+	lexComment
+	lexRawString
+	lexString
+	lexRune
+)
+
+func (c *chunkScanner) init(r io.Reader, size int) {
+	c.r, c.size = r, size
+	c.buf = make([]byte, 0, size)
+	// The scanner is at the end of the empty chunk.
+	c.s.Init(token.NewFileSet().AddFile("", -1, 0), nil, nil, 0)
+}
+
+// scan returns the next token of the input.
+func (c *chunkScanner) scan() (token.Token, string) {
+	for {
+		_, tok, lit := c.s.Scan()
+		if tok != token.EOF {
+			return tok, lit
+		}
+		chunk := c.next()
+		if chunk == nil {
+			return token.EOF, ""
+		}
+		// A new file set doesn't keep the line tables of the previous chunks.
+		file := token.NewFileSet().AddFile("source-input.go", -1, len(chunk))
+		offset, line := c.offset, c.line
+		c.s.Init(file, chunk, func(pos token.Position, msg string) {
+			pos.Offset += offset
+			pos.Line += line
+			c.errs.Add(pos, msg)
+		}, 0)
+		c.offset += len(chunk)
+		c.line += bytes.Count(chunk, []byte{'\n'})
+	}
+}
+
+// next returns the next chunk of the input or nil at the end of it.
+func (c *chunkScanner) next() []byte {
+	for !c.eof && (len(c.buf) < c.size || c.cut == 0) {
+		c.read()
+		c.findCut()
+	}
+	n := c.cut
+	if c.eof {
+		n = len(c.buf)
+	}
+	if n == 0 {
+		return nil
+	}
+	// The scanner keeps the chunk, so the rest is copied.
+	chunk := c.buf[:n]
+	c.buf = append(make([]byte, 0, c.size), c.buf[n:]...)
+	c.scanned -= n
+	c.cut = 0
+	return chunk
+}
+
+func (c *chunkScanner) read() {
+	if len(c.buf) == cap(c.buf) {
+		c.buf = append(c.buf, 0)[:len(c.buf)]
+	}
+	n, err := c.r.Read(c.buf[len(c.buf):cap(c.buf)])
+	c.buf = c.buf[:len(c.buf)+n]
+	if err != nil {
+		if err != io.EOF {
+			c.err = err
+		}
+		c.eof = true
+	}
+}
+
+// findCut advances the state over the bytes read so far
+// and records the last line break a chunk can end with.
+func (c *chunkScanner) findCut() {
+	buf := c.buf
+	for i := c.scanned; i < len(buf); i++ {
+		var next byte
+		switch {
+		case i+1 < len(buf):
+			next = buf[i+1]
+		case !c.eof:
+			// The next byte tells `//`, `/*`, `*/` and the BOM.
+			c.scanned = i
+			return
+		}
+		b := buf[i]
+		switch c.state {
+		case lexCode:
+			switch {
+			case b == '/' && next == '/':
+				c.state = lexLineComment
+				i++
+			case b == '/' && next == '*':
+				c.state = lexComment
+				i++
+			case b == '`':
+				c.state = lexRawString
+			case b == '"':
+				c.state = lexString
+			case b == '\'':
+				c.state = lexRune
+			}
+		case lexComment:
+			if b == '*' && next == '/' {
+				c.state = lexCode
+				i++
+			}
+		case lexRawString:
+			if b == '`' {
+				c.state = lexCode
+			}
+		case lexString, lexRune:
+			switch {
+			case b == '\\' && next != '\n':
+				i++
+			case b == '"' && c.state == lexString, b == '\'' && c.state == lexRune:
+				c.state = lexCode
+			}
+		}
+		if b == '\n' && c.state != lexComment && c.state != lexRawString {
+			// The unterminated strings end at the line break too.
+			c.state = lexCode
+			// The scanner only skips the BOM at the start of the input.
+			if next != 0xEF {
+				c.cut = i + 1
+			}
+		}
+	}
+	c.scanned = len(buf)
+}
@@ -0,0 +1,199 @@
+package minformat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTokenSource(t *testing.T) {
+	tests := []string{
+		`for ;; {}`,
+		`for ; x; {}`,
+		`for ; x; x++ {}`,
+		`for i := 0; ; {}`,
+		`for ; func() bool { return true }(); {}`,
+		`for range ch {}`,
+		`for k, v := range m {}`,
+		`if ; x {}`,
+		`if x := f(); x { return } else if y {}`,
+		`switch ; {}`,
+		`switch ; x {}`,
+		`switch x := f(); {}`,
+		`switch x := y.(type) { case int, string: ; case nil: default: }`,
+		`select { case <-ch: default: return }`,
+		`{ ; f(); ; }`,
+		`L: ; goto L`,
+		`var x, y []int`,
+		`var x, y = 1, 2`,
+		`var f func() (int)`,
+		`x := x / *p - -y + (x < -y) + x &^ y + x & ^y`,
+		`ch <- -1`,
+		`x := <-ch`,
+		`var c1, c2, c3 chan<- chan int`,
+		`var c4 <-chan <-chan int`,
+		`var c5 chan (<-chan int)`,
+		`f(a, b...,)`,
+		`_ = [][]int{{1, 2,}, {3},}`,
+		`_ = x.(interface{ M() (int) })`,
+		`_ = func(a, b []int, c ...int) (n int, err error) { return }`,
+		`_ = func(int, []T, *T, pkg.T, T[int]) (T[int]) { return nil }`,
+		`_ = func(a [2]int, b [N]T, c T[N]) ([]int) { return nil }`,
+	}
+	decls := []string{
+		`import ( _ "x"; . "y"; z "z" )`,
+		`import "fmt"`,
+		`type T [N]int`,
+		`type T [N * M]int`,
+		`type T[P any] []P`,
+		`type T[P *C,] struct{}`,
+		`type T[P *[]int] struct{}`,
+		`type T[P, Q ~int | ~string, R interface{ M() }] int`,
+		`type A[P any] = B[P]`,
+		`type ( A = B; C *D; E func() (int) )`,
+		`type S struct { a, b []int "tag"; T; *U; pkg.V; W[int]; c chan<- int }`,
+		`type I interface { M(x int) (int); io.Reader; ~int | string }`,
+		`func (r *T) M(x []int) (y []int) { return }`,
+		`func (T) M() {}`,
+		`func F[T any, U *T](x T) (U) { return nil }`,
+		`func F()`,
+		`const ( A = iota; B; C int = 2 )`,
+		`var ( a []int; b, c = 1, 2 )`,
+	}
+	var srcs []string
+	for _, test := range tests {
+		srcs = append(srcs, "package p; func f() {"+test+"}")
+	}
+	for _, test := range decls {
+		srcs = append(srcs, "package p; "+test)
+	}
+	srcs = append(srcs, "package p", "package p // comment")
+
+	for _, src := range srcs {
+		want, err := Source([]byte(src))
+		if err != nil {
+			t.Fatalf("parse %s: %v", src, err)
+		}
+		var buf bytes.Buffer
+		if err := TokenSource(&buf, strings.NewReader(src)); err != nil {
+			t.Errorf("minify %s: %v", src, err)
+			continue
+		}
+		if have := buf.String(); have != string(want) {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", src, have, want)
+		}
+	}
+}
+
+func TestTokenSourceErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+		err  bool
+	}{
+		{
+			src:  "package p\nfunc f( {\n\tx := [1, 2) // mismatched\n}",
+			want: `package p;func f({x:=[1,2)}`,
+		},
+		{
+			src:  "package p\nvar s = \"unterminated\nvar t = 1",
+			want: `package p;var s="unterminated;var t=1`,
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := TokenSource(&buf, strings.NewReader(test.src))
+		if _, ok := err.(scanner.ErrorList); ok != test.err {
+			t.Errorf("minify %s: unexpected error: %v", test.src, err)
+		}
+		if have := buf.String(); have != test.want {
+			t.Errorf("minify %s:\nhave: %q\nwant: %q", test.src, have, test.want)
+		}
+	}
+}
+
+func TestTokenSourceChunks(t *testing.T) {
+	srcs := []string{
+		"package p\n\nvar s = `a\nb // c\n/* d`\n\nfunc f() {\n\tx := 1 /* e\nf */\n\ty := '\\n'\n}\n",
+		"package p\nconst c = \"a\\\nb\"\nvar v = '\n/* unterminated\nx",
+		"package p\nvar s = \"unterminated\nvar t = 1",
+		"\xef\xbb\xbfpackage p\n\xef\xbb\xbfvar x = 1\n",
+		"package p\n\nfunc f() (\n\tint,\n) {\n\treturn 0\n}",
+	}
+
+	for _, src := range srcs {
+		var want bytes.Buffer
+		wantErr := fmt.Sprint(TokenSource(&want, strings.NewReader(src)))
+		for size := 1; size < len(src); size++ {
+			var have bytes.Buffer
+			// One byte at a time, so the reads don't match the chunks.
+			err := tokenSource(&have, iotest.OneByteReader(strings.NewReader(src)), size)
+			if have.String() != want.String() || fmt.Sprint(err) != wantErr {
+				t.Errorf("minify %q in %d byte chunks:\nhave: %q, %v\nwant: %q, %s", src, size, have.String(), err, want.String(), wantErr)
+				break
+			}
+		}
+	}
+
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("package p\n"), iotest.ErrReader(errRead))
+	if err := TokenSource(io.Discard, r); err != errRead {
+		t.Errorf("read error: have %v, want %v", err, errRead)
+	}
+}
+
+func TestTokenSourceGoroot(t *testing.T) {
+	walkGoroot(t, func(filename string, src []byte) error {
+		want, err := Source(src)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := TokenSource(&buf, bytes.NewReader(src)); err != nil {
+			return err
+		}
+		if have := buf.Bytes(); !bytes.Equal(have, want) {
+			i := 0
+			for i < len(have) && i < len(want) && have[i] == want[i] {
+				i++
+			}
+			return fmt.Errorf("output differs at %d:\nhave: %q\nwant: %q", i, snippet(have, i), snippet(want, i))
+		}
+		return nil
+	})
+}
+
+func BenchmarkTokenSource(b *testing.B) {
+	_, _, srcs := loadBenchFiles(b)
+	var buf bytes.Buffer
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, src := range srcs {
+			buf.Reset()
+			if err := TokenSource(&buf, bytes.NewReader(src)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkSource(b *testing.B) {
+	_, _, srcs := loadBenchFiles(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, src := range srcs {
+			if _, err := Source(src); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package minformat
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+)
+
+// typeChecker collects the type information for the transformations that need it.
+//
+// The AST is re-checked after every transformation that changes it,
+// so the information never describes a stale tree.
+type typeChecker struct {
+	fset     *token.FileSet
+	importer types.Importer
+
+	pkg  *types.Package
+	info *types.Info
+
+	// errors are all errors reported by the last check.
+	errors []types.Error
+}
+
+func newTypeChecker(cfg *Config, fset *token.FileSet) *typeChecker {
+	imp := cfg.Importer
+	if imp == nil {
+		imp = importer.ForCompiler(fset, "source", nil)
+	}
+	return &typeChecker{fset: fset, importer: imp}
+}
+
+// check type-checks the package files and updates the collected information.
+func (tc *typeChecker) check(files []*ast.File) error {
+	if len(files) == 0 {
+		return nil
+	}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Instances:  make(map[*ast.Ident]types.Instance),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	tc.errors = tc.errors[:0]
+	conf := types.Config{
+		Importer: tc.importer,
+		Error: func(err error) {
+			if err, ok := err.(types.Error); ok {
+				tc.errors = append(tc.errors, err)
+			}
+		},
+	}
+	pkg, err := conf.Check(files[0].Name.Name, tc.fset, files, info)
+	if err != nil {
+		return fmt.Errorf("type-check: %w", err)
+	}
+	tc.pkg = pkg
+	tc.info = info
+	return nil
+}
+
+// resolve returns the objects that the identifiers of files denote.
+//
+// Unlike check, it doesn't import packages and never fails: the references
+// to the imported objects stay unresolved, but the package-level, local
+// and predeclared ones are, which is all the transformations that only
+// tell the identifiers apart need. The files of each package (like the
+// external tests) are resolved separately.
+func (tc *typeChecker) resolve(files []*ast.File) *types.Info {
+	return tc.resolveWith(unresolvedImporter{}, files)
+}
+
+// resolveWith is like resolve, but it imports the packages with imp.
+// The imports imp fails get the names go/types guesses from their paths.
+func (tc *typeChecker) resolveWith(imp types.Importer, files []*ast.File) *types.Info {
+	info := &types.Info{
+		Defs:      make(map[*ast.Ident]types.Object),
+		Uses:      make(map[*ast.Ident]types.Object),
+		Implicits: make(map[ast.Node]types.Object),
+	}
+	for _, pkgFiles := range groupByPackage(files) {
+		conf := types.Config{
+			Importer: imp,
+			Error:    func(error) {},
+		}
+		conf.Check(pkgFiles[0].Name.Name, tc.fset, pkgFiles, info)
+	}
+	return info
+}
+
+// groupByPackage splits files by their package names,
+// keeping the order of the first appearance.
+func groupByPackage(files []*ast.File) [][]*ast.File {
+	var groups [][]*ast.File
+	index := make(map[string]int)
+	for _, f := range files {
+		i, ok := index[f.Name.Name]
+		if !ok {
+			i = len(groups)
+			index[f.Name.Name] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], f)
+	}
+	return groups
+}
+
+// unresolvedImporter fails every import,
+// the type checker then uses an empty package instead.
+type unresolvedImporter struct{}
+
+func (unresolvedImporter) Import(path string) (*types.Package, error) {
+	return nil, fmt.Errorf("%s is not imported", path)
+}
+
+// importObject returns the package name object that spec declares.
+func importObject(info *types.Info, spec *ast.ImportSpec) types.Object {
+	if spec.Name != nil {
+		return info.Defs[spec.Name]
+	}
+	return info.Implicits[spec]
+}
+
+// importUses are the imported packages referenced in some part of a file.
+type importUses struct {
+	names map[types.Object]bool
+	// pkgs are the packages of all referenced objects,
+	// they tell whether the dot imports are used.
+	pkgs map[*types.Package]bool
+}
+
+func collectImportUses(info *types.Info, nodes ...ast.Node) importUses {
+	uses := importUses{
+		names: make(map[types.Object]bool),
+		pkgs:  make(map[*types.Package]bool),
+	}
+	for _, n := range nodes {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if ident, ok := n.(*ast.Ident); ok {
+				switch obj := info.Uses[ident].(type) {
+				case *types.PkgName:
+					uses.names[obj] = true
+				case types.Object:
+					uses.pkgs[obj.Pkg()] = true
+				}
+			}
+			return true
+		})
+	}
+	return uses
+}
+
+// isUsed reports whether the package imported as pkgName is referenced.
+// Blank imports are never used.
+func (uses importUses) isUsed(pkgName *types.PkgName) bool {
+	switch pkgName.Name() {
+	case "_":
+		return false
+	case ".":
+		return uses.pkgs[pkgName.Imported()]
+	default:
+		return uses.names[pkgName]
+	}
+}
+
+// usedImports returns the import specs of f that are referenced inside nodes.
+// Blank imports are never returned.
+func usedImports(info *types.Info, f *ast.File, nodes []ast.Node) []*ast.ImportSpec {
+	uses := collectImportUses(info, nodes...)
+	var list []*ast.ImportSpec
+	for _, spec := range f.Imports {
+		if pkgName, ok := importObject(info, spec).(*types.PkgName); ok && uses.isUsed(pkgName) {
+			list = append(list, spec)
+		}
+	}
+	return list
+}
@@ -0,0 +1,377 @@
+package minformat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+)
+
+// DiffKind is a kind of the check that found a Diff.
+type DiffKind int
+
+const (
+	// ParseDiff means the minified code doesn't parse
+	// or it parses to a different syntax tree.
+	ParseDiff DiffKind = iota
+	// IdempotenceDiff means minifying the minified code again changes it.
+	IdempotenceDiff
+	// TypesDiff means the minified code doesn't type-check
+	// or it gets different type information.
+	TypesDiff
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case ParseDiff:
+		return "parse"
+	case IdempotenceDiff:
+		return "idempotence"
+	case TypesDiff:
+		return "types"
+	}
+	return fmt.Sprintf("DiffKind(%d)", int(k))
+}
+
+// Diff is a difference between the original and the minified code found by Verify.
+type Diff struct {
+	Kind DiffKind
+
+	// Pos is the position of the difference in the original code, if it has one.
+	Pos token.Position
+
+	// Original and Minified describe the different parts of the code.
+	// For the minified code that doesn't parse or type-check, Minified is the error.
+	Original string
+	Minified string
+}
+
+func (d Diff) String() string {
+	prefix := ""
+	if d.Pos.IsValid() {
+		prefix = d.Pos.String() + ": "
+	}
+	return fmt.Sprintf("%s%s difference: original %q, minified %q", prefix, d.Kind, d.Original, d.Minified)
+}
+
+// Verify checks that the minified file parses to the same syntax tree
+// as the original one, ignoring the comments, and that minifying it again
+// doesn't change it. It returns the differences found, at most one of every kind.
+//
+// The file is not modified, but its comments are not printed by the minifier,
+// so the files with the build constraints are not verified to keep them.
+func Verify(fset *token.FileSet, file *ast.File) ([]Diff, error) {
+	var minified bytes.Buffer
+	if err := Node(&minified, fset, file); err != nil {
+		return nil, err
+	}
+	filename := fset.Position(file.Pos()).Filename
+	fset2 := token.NewFileSet()
+	file2, err := parser.ParseFile(fset2, filename, minified.Bytes(), 0)
+	if err != nil {
+		return []Diff{{Kind: ParseDiff, Minified: err.Error()}}, nil
+	}
+
+	var diffs []Diff
+	if x, y := nodeDiff(reflect.ValueOf(file), reflect.ValueOf(file2)); x != nil {
+		d := Diff{Kind: ParseDiff, Pos: fset.Position(x.Pos())}
+		d.Original = nodeString(fset, x)
+		if y != nil {
+			d.Minified = nodeString(fset2, y)
+		}
+		diffs = append(diffs, d)
+	}
+
+	var again bytes.Buffer
+	if err := Node(&again, fset2, file2); err != nil {
+		return nil, err
+	}
+	if have, want := again.Bytes(), minified.Bytes(); !bytes.Equal(have, want) {
+		i := 0
+		for i < len(have) && i < len(want) && have[i] == want[i] {
+			i++
+		}
+		diffs = append(diffs, Diff{
+			Kind:     IdempotenceDiff,
+			Original: snippet(want, i),
+			Minified: snippet(have, i),
+		})
+	}
+	return diffs, nil
+}
+
+// VerifyTypes checks that the minified files of a package get the same type information
+// as the original ones: every identifier refers to the same object and every
+// expression has the same type and constant value.
+// The differences Verify finds in any file are returned first.
+//
+// files must type-check; imp is used to import the dependencies, if it's nil,
+// they are type-checked from the source.
+func VerifyTypes(fset *token.FileSet, files []*ast.File, imp types.Importer) ([]Diff, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	if imp == nil {
+		imp = importer.ForCompiler(fset, "source", nil)
+	}
+	var diffs []Diff
+	fset2 := token.NewFileSet()
+	files2 := make([]*ast.File, len(files))
+	for i, f := range files {
+		d, err := Verify(fset, f)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d...)
+
+		var minified bytes.Buffer
+		if err := Node(&minified, fset, f); err != nil {
+			return nil, err
+		}
+		files2[i], err = parser.ParseFile(fset2, fset.Position(f.Pos()).Filename, minified.Bytes(), 0)
+		if err != nil {
+			// Reported by Verify.
+			return diffs, nil
+		}
+	}
+	if len(diffs) != 0 {
+		// The syntax trees differ, the type information can't be matched.
+		return diffs, nil
+	}
+
+	info, err := checkTypes(fset, files, imp)
+	if err != nil {
+		return nil, err
+	}
+	info2, err := checkTypes(fset2, files2, imp)
+	if err != nil {
+		var typeErr types.Error
+		if errors.As(err, &typeErr) {
+			err = errors.New(typeErr.Msg)
+		}
+		return []Diff{{Kind: TypesDiff, Minified: err.Error()}}, nil
+	}
+
+	for i := range files {
+		x, y := typeFacts(info, files[i]), typeFacts(info2, files2[i])
+		for j := range x {
+			if x[j].fact != y[j].fact {
+				return []Diff{{
+					Kind:     TypesDiff,
+					Pos:      fset.Position(x[j].node.Pos()),
+					Original: x[j].fact,
+					Minified: y[j].fact,
+				}}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func checkTypes(fset *token.FileSet, files []*ast.File, imp types.Importer) (*types.Info, error) {
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: imp}
+	if _, err := conf.Check(files[0].Name.Name, fset, files, info); err != nil {
+		return nil, fmt.Errorf("type-check: %w", err)
+	}
+	return info, nil
+}
+
+// typeFact is a description of the type information of a node
+// that doesn't depend on the positions.
+type typeFact struct {
+	node ast.Node
+	fact string
+}
+
+// typeFacts returns the type information of the f nodes in the traversal order.
+// The files with the same syntax trees produce the facts for the same nodes.
+func typeFacts(info *types.Info, f *ast.File) []typeFact {
+	qualifier := func(pkg *types.Package) string { return pkg.Path() }
+	var facts []typeFact
+	ast.Inspect(f, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok {
+			obj := info.Uses[ident]
+			if obj == nil {
+				obj = info.Defs[ident]
+			}
+			if obj != nil {
+				facts = append(facts, typeFact{ident, types.ObjectString(obj, qualifier)})
+			}
+		}
+		if expr, ok := n.(ast.Expr); ok {
+			if tv, ok := info.Types[expr]; ok {
+				fact := types.TypeString(tv.Type, qualifier)
+				if tv.Value != nil {
+					fact += " = " + tv.Value.ExactString()
+				}
+				facts = append(facts, typeFact{expr, fact})
+			}
+		}
+		return true
+	})
+	return facts
+}
+
+// nodeDiff compares the syntax trees x and y ignoring the positions and comments
+// and returns the innermost nodes that contain the first difference.
+// If y has no node there, only x is returned.
+func nodeDiff(x, y reflect.Value) (ast.Node, ast.Node) {
+	if valueEqual(x, y) || x.IsNil() {
+		return nil, nil
+	}
+	xn := x.Interface().(ast.Node)
+	if y.IsNil() {
+		return xn, nil
+	}
+	yn := y.Interface().(ast.Node)
+	if reflect.TypeOf(xn) != reflect.TypeOf(yn) {
+		// The enclosing node describes the difference better.
+		return nil, nil
+	}
+	if cx, cy := childDiff(reflect.ValueOf(xn).Elem(), reflect.ValueOf(yn).Elem()); cx != nil {
+		return cx, cy
+	}
+	if _, ok := xn.(ast.Spec); ok {
+		// The specs can't be printed without the enclosing declaration.
+		return nil, nil
+	}
+	return xn, yn
+}
+
+// childDiff returns the first different child nodes of the structs x and y.
+func childDiff(x, y reflect.Value) (ast.Node, ast.Node) {
+	for i := 0; i < x.NumField(); i++ {
+		if skipDiffField(x.Type(), i) {
+			continue
+		}
+		fx, fy := x.Field(i), y.Field(i)
+		switch fx.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			if fx.Type().Implements(nodeType) {
+				if cx, cy := nodeDiff(fx, fy); cx != nil {
+					return cx, cy
+				}
+			}
+		case reflect.Slice:
+			if !fx.Type().Elem().Implements(nodeType) {
+				continue
+			}
+			for j := 0; j < fx.Len() && j < fy.Len(); j++ {
+				if cx, cy := nodeDiff(fx.Index(j), fy.Index(j)); cx != nil {
+					return cx, cy
+				}
+			}
+			if fx.Len() > fy.Len() {
+				return fx.Index(fy.Len()).Interface().(ast.Node), nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// structEqual reports whether the structs x and y are equal
+// ignoring the positions, comments and resolved objects.
+func structEqual(x, y reflect.Value) bool {
+	for i := 0; i < x.NumField(); i++ {
+		if skipDiffField(x.Type(), i) {
+			continue
+		}
+		if !valueEqual(x.Field(i), y.Field(i)) {
+			return false
+		}
+	}
+	return true
+}
+
+func valueEqual(x, y reflect.Value) bool {
+	if x.Type() == posType {
+		return x.Interface().(token.Pos).IsValid() == y.Interface().(token.Pos).IsValid()
+	}
+	switch x.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if x.IsNil() || y.IsNil() {
+			return x.IsNil() == y.IsNil()
+		}
+		if x.Elem().Type() != y.Elem().Type() {
+			return false
+		}
+		return valueEqual(x.Elem(), y.Elem())
+	case reflect.Struct:
+		return structEqual(x, y)
+	case reflect.Slice:
+		if x.Len() != y.Len() {
+			return false
+		}
+		for i := 0; i < x.Len(); i++ {
+			if !valueEqual(x.Index(i), y.Index(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.String:
+		return x.String() == y.String()
+	case reflect.Bool:
+		return x.Bool() == y.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return x.Int() == y.Int()
+	}
+	return true
+}
+
+var posType = reflect.TypeOf(token.NoPos)
+
+// skipDiffField reports whether the i-th field of the AST struct type t
+// is not compared by nodeDiff.
+//
+// The positions are skipped except the ones that mark the optional tokens:
+// for them, only the presence is compared.
+func skipDiffField(t reflect.Type, i int) bool {
+	field := t.Field(i)
+	if field.Type == posType {
+		switch {
+		case t == reflect.TypeOf(ast.TypeSpec{}) && field.Name == "Assign":
+			return false
+		case t == reflect.TypeOf(ast.CallExpr{}) && field.Name == "Ellipsis":
+			return false
+		}
+		return true
+	}
+	if field.Type == commentGroupType {
+		return true
+	}
+	switch field.Name {
+	case "Obj", "Scope", "Unresolved", "Comments", "Imports", "GoVersion", "Implicit":
+		// Derived from the other fields, the comments or the line breaks.
+		return true
+	}
+	return false
+}
+
+func nodeString(fset *token.FileSet, n ast.Node) string {
+	var buf bytes.Buffer
+	Node(&buf, fset, n)
+	return buf.String()
+}
+
+// snippet returns a part of the code b around the offset i.
+func snippet(b []byte, i int) string {
+	const context = 20
+	start, end := i-context, i+context
+	if start < 0 {
+		start = 0
+	}
+	if end > len(b) {
+		end = len(b)
+	}
+	return string(b[start:end])
+}
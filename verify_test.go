@@ -0,0 +1,116 @@
+package minformat
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	filenames, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, filename := range filenames {
+		f, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffs, err := Verify(fset, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range diffs {
+			t.Errorf("verify %s: %v", filename, d)
+		}
+		files = append(files, f)
+	}
+
+	diffs, err := VerifyTypes(fset, files, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range diffs {
+		t.Errorf("verify types: %v", d)
+	}
+}
+
+func TestNodeDiff(t *testing.T) {
+	tests := []struct {
+		x        string
+		y        string
+		original string
+		minified string
+	}{
+		{
+			x: `package p; func f() { a := 1 + 2 }`,
+			y: `package p
+
+// f has a comment.
+func f() {
+	a := 1 + 2
+}`,
+		},
+		{
+			x:        `package p; func f() { a := 1 + 2 }`,
+			y:        `package p; func f() { a := 1 - 2 }`,
+			original: `1+2`,
+			minified: `1-2`,
+		},
+		{
+			x:        `package p; func f() { a := (1 + 2) * 3 }`,
+			y:        `package p; func f() { a := 1 + 2*3 }`,
+			original: `(1+2)*3`,
+			minified: `1+2*3`,
+		},
+		{
+			x:        `package p; func f() { a(); b() }`,
+			y:        `package p; func f() { a() }`,
+			original: `b()`,
+		},
+		{
+			x:        `package p; var x, y int`,
+			y:        `package p; var x int`,
+			original: `y`,
+		},
+		{
+			x:        `package p; type A = int`,
+			y:        `package p; type A int`,
+			original: `type A=int`,
+			minified: `type A int`,
+		},
+		{
+			x:        `package p; func f() { g(xs...) }`,
+			y:        `package p; func f() { g(xs) }`,
+			original: `g(xs...)`,
+			minified: `g(xs)`,
+		},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		x, err := parser.ParseFile(fset, "x.go", test.x, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, err := parser.ParseFile(fset, "y.go", test.y, parser.ParseComments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var original, minified string
+		if nx, ny := nodeDiff(reflect.ValueOf(x), reflect.ValueOf(y)); nx != nil {
+			original = nodeString(fset, nx)
+			if ny != nil {
+				minified = nodeString(fset, ny)
+			}
+		}
+		if original != test.original || minified != test.minified {
+			t.Errorf("diff %s:\nhave: %q, %q\nwant: %q, %q", test.x, original, minified, test.original, test.minified)
+		}
+	}
+}